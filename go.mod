@@ -8,6 +8,7 @@ require (
 	github.com/sashabaranov/go-openai v1.41.2
 	github.com/spf13/cobra v1.10.2
 	github.com/spf13/viper v1.21.0
+	golang.org/x/crypto v0.24.0
 	modernc.org/sqlite v1.44.3
 )
 
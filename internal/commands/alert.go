@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/notify"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+	"github.com/spf13/viper"
+)
+
+// DEFAULT_ALERT_THRESHOLD is the default minimum score for an instant alert.
+const DEFAULT_ALERT_THRESHOLD = 90
+
+// DESTINATION_ALERT is the prefix of the delivery destination names of push services.
+const DESTINATION_ALERT = "alert:"
+
+// alertPushers returns the configured push services for instant alerts. A service is set
+// up by giving its URL.
+func alertPushers() ([]notify.Pusher, error) {
+	var pushers []notify.Pusher
+	timeout := viper.GetDuration("notify_timeout")
+
+	if url := viper.GetString("alert_ntfy_url"); url != "" {
+		p, err := notify.NewPusher(notify.TYPE_NTFY, url, viper.GetString("alert_ntfy_token"), timeout)
+		if err != nil {
+			return nil, err
+		}
+		pushers = append(pushers, p)
+	}
+	if url := viper.GetString("alert_gotify_url"); url != "" {
+		p, err := notify.NewPusher(notify.TYPE_GOTIFY, url, viper.GetString("alert_gotify_token"), timeout)
+		if err != nil {
+			return nil, err
+		}
+		pushers = append(pushers, p)
+	}
+	return pushers, nil
+}
+
+// sendInstantAlert pushes an alert about a newly scored article if its score reaches the
+// alert threshold. Each article alerts each service at most once, even if it is rescored.
+func sendInstantAlert(ctx context.Context, db *storage.DB, pushers []notify.Pusher, art storage.Article, score string) {
+	value, err := strconv.Atoi(score)
+	if err != nil || value < viper.GetInt("alert_threshold") {
+		return
+	}
+
+	title := art.Title
+	if art.TranslatedTitle != "" {
+		title = art.TranslatedTitle
+	}
+	alert := notify.Alert{Title: title, Score: value, Link: art.Link}
+
+	for _, p := range pushers {
+		dest := DESTINATION_ALERT + p.Name()
+		sent, err := db.GetDeliveredGUIDs(dest, []string{art.GUID})
+		if err != nil {
+			log.Printf("  Error checking alerts for %s: %v", dest, err)
+			continue
+		}
+		if sent[art.GUID] {
+			continue
+		}
+
+		err = notify.SendAlert(ctx, p, alert, viper.GetInt("notify_retries"))
+		if recordErr := db.RecordDelivery(0, dest, []string{art.GUID}, err); recordErr != nil {
+			log.Printf("  Error recording alert for %s: %v", dest, recordErr)
+		}
+		if err != nil {
+			log.Printf("  Error sending alert to %s: %v", p.Name(), err)
+			continue
+		}
+		fmt.Printf("  Alert sent to %s\n", p.Name())
+	}
+}
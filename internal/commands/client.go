@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 
 	openai "github.com/sashabaranov/go-openai"
@@ -18,3 +19,26 @@ func NewAIClient() (*openai.Client, error) {
 	config.BaseURL = viper.GetString("base_url")
 	return openai.NewClientWithConfig(config), nil
 }
+
+// chatCompletion sends a single user prompt to the model and returns the text of the reply.
+func chatCompletion(ctx context.Context, client *openai.Client, model, prompt string) (string, error) {
+	resp, err := client.CreateChatCompletion(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model: model,
+			Messages: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: prompt,
+				},
+			},
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("model returned no choices")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/email"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+	"github.com/spf13/viper"
+)
+
+// Prefixes of delivery destination names.
+const (
+	DESTINATION_FILE  = "file:"
+	DESTINATION_EMAIL = "email:"
+)
+
+// reportBuilder builds a report holding the given articles.
+type reportBuilder func(articles []storage.Article) *report.Report
+
+// emailDestination names the delivery destination for an email recipient.
+func emailDestination(recipient string) string {
+	if a, err := mail.ParseAddress(recipient); err == nil {
+		recipient = a.Address
+	}
+	return DESTINATION_EMAIL + strings.ToLower(recipient)
+}
+
+// fileDestination names the delivery destination for a report file.
+func fileDestination(path string) string {
+	return DESTINATION_FILE + path
+}
+
+// reportGUIDs returns the GUIDs of every article in a report, including those folded into
+// a story.
+func reportGUIDs(articles []storage.Article) []string {
+	var guids []string
+	for _, art := range articles {
+		guids = append(guids, art.GUID)
+		for _, related := range art.Related {
+			guids = append(guids, related.GUID)
+		}
+	}
+	return guids
+}
+
+// undelivered returns the articles that have not reached every one of the destinations yet,
+// so that a destination is not sent articles again only because another destination failed.
+// With --always, every article is returned, since they are wanted whether delivered or not.
+func undelivered(destinations []string, articles []storage.Article) ([]storage.Article, error) {
+	if reportAlways {
+		return articles, nil
+	}
+
+	guids := reportGUIDs(articles)
+	received := make(map[string]int)
+	for _, dest := range destinations {
+		delivered, err := DB.GetDeliveredGUIDs(dest, guids)
+		if err != nil {
+			return nil, err
+		}
+		for guid := range delivered {
+			received[guid]++
+		}
+	}
+
+	var pending []storage.Article
+	for _, art := range articles {
+		if received[art.GUID] < len(destinations) {
+			pending = append(pending, art)
+		}
+	}
+	return pending, nil
+}
+
+// anyUndelivered returns true if any of the destinations has not received all of the
+// articles yet.
+func anyUndelivered(destinations []string, articles []storage.Article) (bool, error) {
+	for _, dest := range destinations {
+		pending, err := undelivered([]string{dest}, articles)
+		if err != nil {
+			return false, err
+		}
+		if len(pending) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordUpToDate records a delivery of nothing to a destination that already has every
+// article, so that resending the report does not take it for one that was never attempted.
+func recordUpToDate(reportID int64, destination string) {
+	recordDelivery(reportID, destination, nil, nil)
+}
+
+// recordDelivery stores the outcome of delivering articles to a destination, logging rather
+// than failing if that is not possible, since the delivery itself already happened.
+func recordDelivery(reportID int64, destination string, guids []string, deliveryErr error) {
+	if err := DB.RecordDelivery(reportID, destination, guids, deliveryErr); err != nil {
+		log.Printf("Error recording delivery to %s: %v", destination, err)
+	}
+}
+
+// writeReportFile writes the report of the articles to a file and records the result.
+func writeReportFile(reportID int64, path, format string, articles []storage.Article, build reportBuilder) error {
+	err := build(articles).GenerateFile(path, format)
+	recordDelivery(reportID, fileDestination(path), reportGUIDs(articles), err)
+	return err
+}
+
+// sendReportEmail emails the report of the articles to the message's recipients and records
+// the result against each of them.
+func sendReportEmail(reportID int64, msg email.Message, articles []storage.Article, build reportBuilder) error {
+	err := build(articles).GenerateEmail(msg, emailConfig())
+	guids := reportGUIDs(articles)
+	for _, rcpt := range msg.Recipients() {
+		recordDelivery(reportID, emailDestination(rcpt), guids, err)
+	}
+	return err
+}
+
+// deliverEmail emails the report to the configured recipients and returns an error for each
+// recipient that could not be reached.
+//
+// By default one message goes to all recipients, holding the articles that some of them have
+// not received yet. With perRecipient, each recipient gets their own message holding only the
+// articles they have not received yet, so one bad address does not hold back everybody else.
+func deliverEmail(reportID int64, articles []storage.Article, build reportBuilder, perRecipient bool) []error {
+	msg := emailMessage()
+	smarthost := viper.GetString("email_smarthost")
+
+	if !perRecipient {
+		var destinations []string
+		for _, rcpt := range msg.Recipients() {
+			destinations = append(destinations, emailDestination(rcpt))
+		}
+		pending, err := undelivered(destinations, articles)
+		if err != nil {
+			return []error{fmt.Errorf("email: error fetching deliveries: %v", err)}
+		}
+		if len(pending) == 0 {
+			log.Println("Nothing new for the email recipients.")
+			for _, dest := range destinations {
+				recordUpToDate(reportID, dest)
+			}
+			return nil
+		}
+
+		log.Printf("Sending email to %s via %s...", strings.Join(msg.Recipients(), ", "), smarthost)
+		if err := sendReportEmail(reportID, msg, pending, build); err != nil {
+			var errs []error
+			for _, rcpt := range msg.Recipients() {
+				errs = append(errs, fmt.Errorf("%s: %v", rcpt, err))
+			}
+			return errs
+		}
+		log.Println("Email sent successfully.")
+		return nil
+	}
+
+	var errs []error
+	for _, rcpt := range msg.Recipients() {
+		pending, err := undelivered([]string{emailDestination(rcpt)}, articles)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: error fetching deliveries: %v", rcpt, err))
+			continue
+		}
+		if len(pending) == 0 {
+			log.Printf("Nothing new for %s.", rcpt)
+			recordUpToDate(reportID, emailDestination(rcpt))
+			continue
+		}
+
+		single := msg
+		single.To, single.Cc, single.Bcc = []string{rcpt}, nil, nil
+		log.Printf("Sending %d articles to %s via %s...", len(pending), rcpt, smarthost)
+		if err := sendReportEmail(reportID, single, pending, build); err != nil {
+			log.Printf("Error sending email to %s: %v", rcpt, err)
+			errs = append(errs, fmt.Errorf("%s: %v", rcpt, err))
+			continue
+		}
+		log.Printf("Email sent to %s.", rcpt)
+	}
+	return errs
+}
+
+// finishReport records the outcome of delivering a report, and marks as reported the
+// articles that have now reached every one of its destinations. Articles that some
+// destination has not received stay unreported, so they are retried on the next run. It
+// returns an error if no destination could be reached.
+func finishReport(rec *storage.Report, articles []storage.Article, errs []error) error {
+	status := storage.REPORT_SENT
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	switch {
+	case len(errs) >= len(rec.Destinations):
+		status = storage.REPORT_FAILED
+	case len(errs) > 0:
+		status = storage.REPORT_PARTIAL
+		log.Printf("Delivery of report %d failed for %d of %d destinations; their articles will be retried on the next run.",
+			rec.ID, len(errs), len(rec.Destinations))
+	}
+	if err := DB.UpdateReportStatus(rec.ID, status, strings.Join(messages, "; ")); err != nil {
+		return fmt.Errorf("error updating report status: %v", err)
+	}
+
+	guids := reportGUIDs(articles)
+	received := make(map[string]int)
+	for _, dest := range rec.Destinations {
+		delivered, err := DB.GetDeliveredGUIDs(dest, guids)
+		if err != nil {
+			return fmt.Errorf("error fetching deliveries: %v", err)
+		}
+		for guid := range delivered {
+			received[guid]++
+		}
+	}
+	var done []string
+	for _, guid := range guids {
+		if received[guid] == len(rec.Destinations) {
+			done = append(done, guid)
+		}
+	}
+	if err := DB.MarkArticlesReported(done); err != nil {
+		return fmt.Errorf("error marking articles as reported: %v", err)
+	}
+	log.Printf("Marked %d articles as reported.", len(done))
+
+	if status == storage.REPORT_FAILED {
+		return fmt.Errorf("delivery of report %d failed: %s", rec.ID, strings.Join(messages, "; "))
+	}
+	return nil
+}
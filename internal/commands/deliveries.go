@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var deliveriesLimit int
+
+var deliveriesCmd = &cobra.Command{
+	Use:   "deliveries",
+	Short: "List recent report delivery attempts and their results",
+	Run: func(cmd *cobra.Command, args []string) {
+		runDeliveries()
+	},
+}
+
+func init() {
+	deliveriesCmd.Flags().IntVar(&deliveriesLimit, "limit", 50, "Number of delivery attempts to list")
+}
+
+func runDeliveries() {
+	deliveries, err := DB.ListDeliveries(deliveriesLimit)
+	if err != nil {
+		fatalf("Error listing deliveries: %v", err)
+	}
+
+	for _, d := range deliveries {
+		line := fmt.Sprintf("%s report %-4d %-6s %-40s %3d articles", d.AttemptedAt.Local().Format("2006-01-02 15:04"), d.ReportID, d.Status, d.Destination, d.Articles)
+		if d.Error != "" {
+			line += ": " + d.Error
+		}
+		fmt.Println(line)
+	}
+}
@@ -2,7 +2,7 @@ package commands
 
 import (
 	"fmt"
-	"log"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -19,7 +19,7 @@ func runDump() {
 	// List up to 1000 recent articles
 	articles, err := DB.ListArticles(1000)
 	if err != nil {
-		log.Fatalf("Error listing articles: %v", err)
+		fatalf("Error listing articles: %v", err)
 	}
 
 	for _, art := range articles {
@@ -31,6 +31,13 @@ func runDump() {
 		fmt.Printf("Feed URL:    %s\n", art.FeedURL)
 		fmt.Printf("Score:       %s\n", art.Score)
 		fmt.Printf("Model:       %s\n", art.Model)
+		fmt.Printf("Language:    %s\n", art.Language)
+		fmt.Printf("Tags:        %s\n", strings.Join(art.Tags, ", "))
+		if art.TranslatedTitle != "" {
+			fmt.Printf("Translated:  %s\n", art.TranslatedTitle)
+			fmt.Println("Translated Summary:")
+			fmt.Println(art.TranslatedSummary)
+		}
 		fmt.Println("Analysis:")
 		fmt.Println(art.Analysis)
 		fmt.Println("Description:")
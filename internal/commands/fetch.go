@@ -1,11 +1,16 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/microcosm-cc/bluemonday"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/dedup"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/language"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/rss"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/utils"
@@ -17,22 +22,51 @@ import (
 // This is to prevent the database from growing too large, in case articles are long.
 const MAX_DB_CONTENT_LENGTH = 4096
 
+// DUPLICATE_WINDOW_DAYS is how far back, by publish date, new articles are compared against
+// stored ones when looking for duplicates.
+const DUPLICATE_WINDOW_DAYS = 14
+
 var fetchCmd = &cobra.Command{
 	Use:   "fetch",
 	Short: "Fetch articles from RSS feed and save to DB",
 	Run: func(cmd *cobra.Command, args []string) {
-		runFetch()
+		runFetch(cmd.Context())
 	},
 }
 
-func runFetch() {
-	if err := fetchAndSave(DB, viper.GetString("feed_url")); err != nil {
+func runFetch(ctx context.Context) {
+	if err := fetchFeeds(ctx, DB); err != nil {
 		log.Printf("Error fetching feed: %v", err)
 	}
 }
 
-func fetchAndSave(db *storage.DB, url string) error {
-	feed, err := rss.FetchFeed(url)
+// fetchFeeds fetches the configured feed, and each feed subscribed to through the API. A feed
+// that fails does not stop the others from being fetched; the errors are returned together.
+func fetchFeeds(ctx context.Context, db *storage.DB) error {
+	urls, err := db.GetSubscribedFeeds()
+	if err != nil {
+		return fmt.Errorf("error listing subscribed feeds: %w", err)
+	}
+	if configured := viper.GetString("feed_url"); configured != "" && !slices.Contains(urls, configured) {
+		urls = append([]string{configured}, urls...)
+	}
+
+	var errs []error
+	for _, url := range urls {
+		if err := fetchAndSave(ctx, db, url); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetchAndSave fetches the feed and saves its new articles. If the context is cancelled,
+// it stops after the article being saved and returns the context's error.
+func fetchAndSave(ctx context.Context, db *storage.DB, url string) error {
+	feed, err := rss.FetchFeed(ctx, url)
 	if err != nil {
 		return err
 	}
@@ -40,11 +74,20 @@ func fetchAndSave(db *storage.DB, url string) error {
 	p := bluemonday.StrictPolicy()
 	fmt.Println("Fetching latest items from ", url)
 
+	index, err := loadDuplicateIndex(db)
+	if err != nil {
+		return fmt.Errorf("error loading articles for duplicate detection: %w", err)
+	}
+
 	received := len(feed.Items)
 	existing := 0
 	added := 0
+	duplicates := 0
 
 	for _, item := range feed.Items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		effectiveGUID := item.GUID
 		if effectiveGUID == "" {
 			effectiveGUID = item.Link
@@ -67,7 +110,18 @@ func fetchAndSave(db *storage.DB, url string) error {
 		content = p.Sanitize(content)
 		desc := p.Sanitize(item.Description)
 
-		fmt.Printf("- New: %s\n", item.Title)
+		canonicalURL := dedup.CanonicalizeURL(item.Link)
+		fingerprint := dedup.SimHash(item.Title + "\n" + utils.TrimString(content, MAX_DB_CONTENT_LENGTH))
+		clusterID := index.Find(canonicalURL, fingerprint)
+		if clusterID != "" {
+			fmt.Printf("- Duplicate: %s (of %s)\n", item.Title, clusterID)
+			if err := db.SetClusterID(clusterID, clusterID); err != nil {
+				log.Printf("Error updating cluster for %s: %v", clusterID, err)
+			}
+		} else {
+			fmt.Printf("- New: %s\n", item.Title)
+			clusterID = effectiveGUID
+		}
 
 		pubDate := time.Now()
 		if item.PublishedParsed != nil {
@@ -85,15 +139,58 @@ func fetchAndSave(db *storage.DB, url string) error {
 			Score:         "",
 			Analysis:      "",
 			FeedURL:       url,
+			Language:      language.Detect(item.Title + "\n" + desc),
+			CanonicalURL:  canonicalURL,
+			Fingerprint:   fingerprint,
+			ClusterID:     clusterID,
 		}
 		if err := db.SaveArticle(art); err != nil {
 			log.Printf("Error saving article to DB: %v", err)
-		} else {
-			added++
+			continue
 		}
+		added++
+		if art.IsDuplicate() {
+			duplicates++
+		}
+		index.Add(dedup.Entry{ClusterID: clusterID, CanonicalURL: canonicalURL, Fingerprint: fingerprint})
+	}
+
+	if err := db.SaveFeed(url, feed.Title, time.Now()); err != nil {
+		log.Printf("Error saving feed %s: %v", url, err)
 	}
 
-	fmt.Printf("Fetch Complete: Received: %d, Existing: %d, Added: %d\n", received, existing, added)
+	fmt.Printf("Fetch Complete: Received: %d, Existing: %d, Added: %d, Duplicates: %d\n", received, existing, added, duplicates)
 
 	return nil
 }
+
+// loadDuplicateIndex builds an index of recent articles for finding duplicates of new ones.
+// Articles stored before duplicate detection existed have their canonical URL and fingerprint
+// computed on the fly.
+func loadDuplicateIndex(db *storage.DB) (*dedup.Index, error) {
+	since := time.Now().Add(-DUPLICATE_WINDOW_DAYS * 24 * time.Hour)
+	articles, err := db.GetArticlesAfter(since)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]dedup.Entry, 0, len(articles))
+	for _, art := range articles {
+		entry := dedup.Entry{
+			ClusterID:    art.ClusterID,
+			CanonicalURL: art.CanonicalURL,
+			Fingerprint:  art.Fingerprint,
+		}
+		if entry.ClusterID == "" {
+			entry.ClusterID = art.GUID
+		}
+		if entry.CanonicalURL == "" {
+			entry.CanonicalURL = dedup.CanonicalizeURL(art.Link)
+		}
+		if entry.Fingerprint == 0 {
+			entry.Fingerprint = dedup.SimHash(art.Title + "\n" + art.Content)
+		}
+		entries = append(entries, entry)
+	}
+	return dedup.NewIndex(entries), nil
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var hashPasswordCmd = &cobra.Command{
+	Use:   "hash-password <name>",
+	Short: "Read a password from stdin and print the --auth-users entry for it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fatalf("Error reading password: %v", err)
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			fatalf("The password must not be empty")
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			fatalf("Error hashing password: %v", err)
+		}
+		fmt.Printf("%s:%s\n", args[0], hash)
+	},
+}
@@ -2,11 +2,18 @@ package commands
 
 import (
 	"fmt"
-	"log"
+	"strings"
 
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
 	"github.com/spf13/cobra"
 )
 
+var (
+	listTag   string
+	listByTag bool
+)
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List recent articles and their scores",
@@ -15,17 +22,41 @@ var listCmd = &cobra.Command{
 	},
 }
 
+func init() {
+	listCmd.Flags().StringVar(&listTag, "tag", "", "Only list articles with this topic tag")
+	listCmd.Flags().BoolVar(&listByTag, "by-tag", false, "Group the listed articles by topic tag")
+}
+
 func runList() {
-	articles, err := DB.ListArticles(1000) // TODO: make this configurable
+	articles, err := DB.ListArticlesFiltered(1000, false, storage.NormalizeTag(listTag)) // TODO: make this configurable
 	if err != nil {
-		log.Fatalf("Error listing articles: %v", err)
+		fatalf("Error listing articles: %v", err)
 	}
 
-	for _, art := range articles {
-		score := art.Score
-		if score == "" {
-			score = "---"
+	if !listByTag {
+		for _, art := range articles {
+			printListLine(art)
 		}
-		fmt.Printf("[%3s] %s (%s)\n", score, art.Title, art.PublishedDate.Format("2006-01-02"))
+		return
+	}
+
+	for _, group := range report.GroupByTag(articles) {
+		fmt.Printf("== %s (%d)\n", group.Name, len(group.Articles))
+		for _, art := range group.Articles {
+			printListLine(art)
+		}
+		fmt.Println()
+	}
+}
+
+func printListLine(art storage.Article) {
+	score := art.Score
+	if score == "" {
+		score = "---"
+	}
+	tags := ""
+	if len(art.Tags) > 0 {
+		tags = " [" + strings.Join(art.Tags, ", ") + "]"
 	}
+	fmt.Printf("[%3s] %s (%s)%s\n", score, art.Title, art.PublishedDate.Format("2006-01-02"), tags)
 }
@@ -3,7 +3,6 @@ package commands
 import (
 	"context"
 	"fmt"
-	"log"
 
 	"github.com/spf13/cobra"
 )
@@ -12,22 +11,22 @@ var listModelsCmd = &cobra.Command{
 	Use:   "listmodels",
 	Short: "List available models from the AI provider",
 	Run: func(cmd *cobra.Command, args []string) {
-		runListModels()
+		runListModels(cmd.Context())
 	},
 }
 
 // runListModels lists the available models from the AI provider.
 // This is useful when switching providers, as not all providers name them
 // the same way.
-func runListModels() {
+func runListModels(ctx context.Context) {
 	client, err := NewAIClient()
 	if err != nil {
-		log.Fatalf("Error creating client: %v", err)
+		fatalf("Error creating client: %v", err)
 	}
 
-	models, err := client.ListModels(context.Background())
+	models, err := client.ListModels(ctx)
 	if err != nil {
-		log.Fatalf("Error listing models: %v", err)
+		fatalf("Error listing models: %v", err)
 	}
 
 	fmt.Printf("Found %d models:\n", len(models.Models))
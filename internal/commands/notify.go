@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/notify"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// DESTINATION_NOTIFY is the prefix of the delivery destination names of notifiers.
+const DESTINATION_NOTIFY = "notify:"
+
+var testNotifyCmd = &cobra.Command{
+	Use:   "test-notify",
+	Short: "Send a sample report to every configured notification channel",
+	Run: func(cmd *cobra.Command, args []string) {
+		runTestNotify(cmd.Context())
+	},
+}
+
+// channel is a configured notifier along with the minimum score of articles sent to it.
+type channel struct {
+	notifier  notify.Notifier
+	threshold int
+}
+
+// notifyDestination names the delivery destination for a notifier.
+func notifyDestination(n notify.Notifier) string {
+	return DESTINATION_NOTIFY + n.Name()
+}
+
+// notifyConfigs returns the configuration of every notification channel that has been
+// set up. A channel is set up by giving its URL.
+func notifyConfigs() []notify.Config {
+	var configs []notify.Config
+	timeout := viper.GetDuration("notify_timeout")
+
+	if url := viper.GetString("notify_slack_url"); url != "" {
+		configs = append(configs, notify.Config{Type: notify.TYPE_SLACK, URL: url, Threshold: viper.GetInt("notify_slack_threshold"), Timeout: timeout})
+	}
+	if url := viper.GetString("notify_discord_url"); url != "" {
+		configs = append(configs, notify.Config{Type: notify.TYPE_DISCORD, URL: url, Threshold: viper.GetInt("notify_discord_threshold"), Timeout: timeout})
+	}
+	if url := viper.GetString("notify_matrix_homeserver"); url != "" {
+		configs = append(configs, notify.Config{
+			Type:      notify.TYPE_MATRIX,
+			URL:       url,
+			Room:      viper.GetString("notify_matrix_room"),
+			Token:     viper.GetString("notify_matrix_token"),
+			Threshold: viper.GetInt("notify_matrix_threshold"),
+			Timeout:   timeout,
+		})
+	}
+	if url := viper.GetString("notify_webhook_url"); url != "" {
+		configs = append(configs, notify.Config{
+			Type:      notify.TYPE_WEBHOOK,
+			URL:       url,
+			Template:  viper.GetString("notify_webhook_template"),
+			Threshold: viper.GetInt("notify_webhook_threshold"),
+			Timeout:   timeout,
+		})
+	}
+	return configs
+}
+
+// notifyChannels creates the configured notifiers.
+func notifyChannels() ([]channel, error) {
+	var channels []channel
+	for _, cfg := range notifyConfigs() {
+		// The webhook template may be given as @filename, like the prompt.
+		if strings.HasPrefix(cfg.Template, "@") {
+			filename := strings.TrimPrefix(cfg.Template, "@")
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, fmt.Errorf("error reading webhook template %s: %w", filename, err)
+			}
+			cfg.Template = string(content)
+		}
+		n, err := notify.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel{notifier: n, threshold: cfg.Threshold})
+	}
+	return channels, nil
+}
+
+// aboveThreshold splits articles into those whose score reaches the threshold and the
+// GUIDs of the rest, including articles folded into their stories.
+func aboveThreshold(articles []storage.Article, threshold int) ([]storage.Article, []string) {
+	var above []storage.Article
+	var below []storage.Article
+	for _, art := range articles {
+		score, err := strconv.Atoi(art.Score)
+		if err != nil {
+			score = 0
+		}
+		if score >= threshold {
+			above = append(above, art)
+		} else {
+			below = append(below, art)
+		}
+	}
+	return above, reportGUIDs(below)
+}
+
+// notifyChannel sends the articles that reach the channel's threshold to it, with retries,
+// and records the result. Articles below the threshold are recorded as skipped, so they do
+// not stay unreported forever.
+func notifyChannel(ctx context.Context, reportID int64, ch channel, articles []storage.Article, build reportBuilder) error {
+	dest := notifyDestination(ch.notifier)
+	above, below := aboveThreshold(articles, ch.threshold)
+	if err := DB.SkipDelivery(dest, below); err != nil {
+		log.Printf("Error recording skipped articles for %s: %v", dest, err)
+	}
+	if len(above) == 0 {
+		log.Printf("No articles reach the threshold of %s.", ch.notifier.Name())
+		recordUpToDate(reportID, dest)
+		return nil
+	}
+
+	log.Printf("Notifying %s of %d articles...", ch.notifier.Name(), len(above))
+	err := notify.Send(ctx, ch.notifier, build(above), viper.GetInt("notify_retries"))
+	recordDelivery(reportID, dest, reportGUIDs(above), err)
+	if err != nil {
+		log.Printf("Error notifying %s: %v", ch.notifier.Name(), err)
+		return err
+	}
+	log.Printf("Notified %s.", ch.notifier.Name())
+	return nil
+}
+
+// deliverNotifications sends every configured notification channel the articles of the report
+// it has not received yet, and returns an error for each channel that could not be reached.
+func deliverNotifications(ctx context.Context, reportID int64, channels []channel, articles []storage.Article, build reportBuilder) []error {
+	var errs []error
+	for _, ch := range channels {
+		pending, err := undelivered([]string{notifyDestination(ch.notifier)}, articles)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: error fetching deliveries: %v", ch.notifier.Name(), err))
+			continue
+		}
+		if len(pending) == 0 {
+			log.Printf("Nothing new for %s.", ch.notifier.Name())
+			recordUpToDate(reportID, notifyDestination(ch.notifier))
+			continue
+		}
+		if err := notifyChannel(ctx, reportID, ch, pending, build); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", ch.notifier.Name(), err))
+		}
+	}
+	return errs
+}
+
+func runTestNotify(ctx context.Context) {
+	channels, err := notifyChannels()
+	if err != nil {
+		fatalf("Error configuring notifications: %v", err)
+	}
+	if len(channels) == 0 {
+		fatalf("No notification channels are configured.")
+	}
+
+	rep := report.SampleReport()
+	failed := false
+	for _, ch := range channels {
+		log.Printf("Sending test notification to %s...", ch.notifier.Name())
+		if err := notify.Send(ctx, ch.notifier, rep, 0); err != nil {
+			log.Printf("Error notifying %s: %v", ch.notifier.Name(), err)
+			failed = true
+			continue
+		}
+		log.Printf("Test notification sent to %s.", ch.notifier.Name())
+	}
+	if failed {
+		fatalf("Some test notifications failed.")
+	}
+}
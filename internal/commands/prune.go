@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// DEFAULT_PRUNE_DAYS is the default age in days after which articles are pruned.
+const DEFAULT_PRUNE_DAYS = 90
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete articles older than --prune-days from the database",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pruneArticles(DB, viper.GetInt("prune_days")); err != nil {
+			fatalf("Error pruning articles: %v", err)
+		}
+	},
+}
+
+// pruneArticles deletes articles published more than the given number of days ago.
+func pruneArticles(db *storage.DB, days int) error {
+	if days <= 0 {
+		return fmt.Errorf("prune days must be positive, not %d", days)
+	}
+
+	before := time.Now().Add(time.Duration(-days) * 24 * time.Hour)
+	count, err := db.PruneArticles(before)
+	if err != nil {
+		return err
+	}
+	log.Printf("Pruned %d articles published before %s.", count, before.Format("2006-01-02"))
+	return nil
+}
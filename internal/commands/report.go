@@ -1,48 +1,98 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/story"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	reportAge       int
-	reportThreshold int
-	reportOut       string
-	reportSendEmail bool
-	reportAlways    bool
+	reportAge        int
+	reportThreshold  int
+	reportOut        string
+	reportSendEmail  bool
+	reportNotify     bool
+	reportAlways     bool
+	reportTag        string
+	reportByTag      bool
+	reportGroupBy    string
+	reportSort       string
+	reportMax        int
+	reportStories    bool
+	reportSimilarity float64
+	reportCheck      bool
+	reportFormat     string
 )
 
 var reportCmd = &cobra.Command{
 	Use:   "report",
-	Short: "Generate an HTML report of high-scoring articles",
+	Short: "Generate a report of high-scoring articles",
 	Run: func(cmd *cobra.Command, args []string) {
-		runReport()
+		if !cmd.Flags().Changed("out") {
+			reportOut = "report" + report.Extension(reportFormat)
+		}
+		runReport(cmd.Context())
 	},
 }
 
 func init() {
 	reportCmd.Flags().IntVar(&reportAge, "age", 7, "Age of articles in days to include in report")
 	reportCmd.Flags().IntVar(&reportThreshold, "threshold", 50, "Score threshold for report")
-	reportCmd.Flags().StringVar(&reportOut, "out", "report.html", "Output filename for the report")
+	reportCmd.Flags().StringVar(&reportOut, "out", "report.html", "Output filename for the report, or - for standard output")
+	reportCmd.Flags().StringVar(&reportFormat, "format", report.FORMAT_HTML, "Report file format: "+strings.Join(report.FORMATS, ", "))
 	reportCmd.Flags().BoolVar(&reportSendEmail, "send-email", false, "Send report via email")
+	reportCmd.Flags().BoolVar(&reportNotify, "notify", false, "Send report to the configured chat notification channels")
 	reportCmd.Flags().BoolVar(&reportAlways, "always", false, "Include articles that have already been reported")
+	reportCmd.Flags().StringVar(&reportTag, "tag", "", "Only include articles with this topic tag")
+	reportCmd.Flags().BoolVar(&reportByTag, "by-tag", false, "Group the report into sections by topic tag")
+	utils.Ckerr(reportCmd.Flags().MarkDeprecated("by-tag", "use --group-by tag instead"))
+	reportCmd.Flags().StringVar(&reportGroupBy, "group-by", "", "Group the report into sections: "+strings.Join(report.GROUPS, ", "))
+	reportCmd.Flags().StringVar(&reportSort, "sort", report.SORT_DATE, "Order of the articles in the report: "+strings.Join(report.SORTS, ", "))
+	reportCmd.Flags().IntVar(&reportMax, "max-articles", 0, "Include at most this many of the highest scoring articles (0 means no limit)")
+	reportCmd.Flags().BoolVar(&reportStories, "stories", false, "Combine related articles about the same story into one entry")
+	reportCmd.Flags().Float64Var(&reportSimilarity, "story-similarity", story.DEFAULT_SIMILARITY, "Minimum similarity (0-1) for articles to be combined into a story")
+	reportCmd.Flags().BoolVar(&reportCheck, "check-template", false, "Validate the report template by rendering it with sample data, then exit")
 }
 
-func runReport() {
-	if err := generateReport(); err != nil {
-		log.Fatalf("Error running report: %v", err)
+func runReport(ctx context.Context) {
+	if reportCheck {
+		runCheckTemplate()
+		return
+	}
+	if err := generateReport(ctx); err != nil {
+		fatalf("Error running report: %v", err)
 	}
 }
 
-func generateReport() error {
+// generateReport selects the articles for a report, and writes and sends it to every
+// destination. It does not start if the context is already cancelled.
+func generateReport(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !report.ValidFormat(reportFormat) {
+		return fmt.Errorf("unknown report format %q; must be one of %s", reportFormat, strings.Join(report.FORMATS, ", "))
+	}
+	if reportByTag && reportGroupBy == "" {
+		reportGroupBy = report.GROUP_TAG
+	}
+	if !report.ValidGroup(reportGroupBy) {
+		return fmt.Errorf("unknown report grouping %q; must be one of %s", reportGroupBy, strings.Join(report.GROUPS, ", "))
+	}
+	if !report.ValidSort(reportSort) {
+		return fmt.Errorf("unknown report sort order %q; must be one of %s", reportSort, strings.Join(report.SORTS, ", "))
+	}
+
 	since := time.Now().Add(time.Duration(-reportAge) * 24 * time.Hour)
 	var articles []storage.Article
 	var err error
@@ -62,70 +112,165 @@ func generateReport() error {
 		return nil
 	}
 
-	var validArticles []storage.Article
-	for _, art := range articles {
-		score, err := strconv.Atoi(art.Score)
-		if err != nil {
-			// If the article has no score, or the score is not an integer, then treat it as 0.
-			score = 0
-		}
-		if score >= reportThreshold {
-			validArticles = append(validArticles, art)
-		}
-	}
+	validArticles := report.Select(articles, reportThreshold, storage.NormalizeTag(reportTag))
 
 	if len(validArticles) == 0 {
 		log.Println("No articles met the score threshold. Skipping report.")
 		return nil
 	}
 
+	if err := DB.LoadDuplicates(validArticles); err != nil {
+		return fmt.Errorf("error fetching duplicate articles: %v", err)
+	}
+
+	if reportStories {
+		validArticles = story.Group(validArticles, reportSimilarity)
+	}
+
+	// Articles left out stay unreported, so they are still candidates for the next report.
+	validArticles, omitted := report.Limit(validArticles, reportMax)
+
 	title := fmt.Sprintf("AI RSS Report (%d days, score >= %d)", reportAge, reportThreshold)
 
 	// Ensure that we're sending the report somehwere
-	if !reportSendEmail && reportOut == "" {
-		return fmt.Errorf("error: must specify --out or --send-email")
+	if !reportSendEmail && !reportNotify && reportOut == "" {
+		return fmt.Errorf("error: must specify --out, --send-email or --notify")
+	}
+
+	rec := &storage.Report{
+		Title:     title,
+		Format:    reportFormat,
+		Threshold: reportThreshold,
+		AgeDays:   reportAge,
+		GroupBy:   reportGroupBy,
+		SortOrder: reportSort,
+		Omitted:   omitted,
+	}
+	if reportOut != "" {
+		rec.Destinations = append(rec.Destinations, fileDestination(reportOut))
+	}
+	if reportSendEmail {
+		recipients := emailMessage().Recipients()
+		if len(recipients) == 0 {
+			return fmt.Errorf("error: no email recipients configured")
+		}
+		for _, rcpt := range recipients {
+			rec.Destinations = append(rec.Destinations, emailDestination(rcpt))
+		}
+	}
+	var channels []channel
+	if reportNotify {
+		channels, err = notifyChannels()
+		if err != nil {
+			return fmt.Errorf("error configuring notifications: %v", err)
+		}
+		if len(channels) == 0 {
+			return fmt.Errorf("error: no notification channels configured")
+		}
+		for _, ch := range channels {
+			rec.Destinations = append(rec.Destinations, notifyDestination(ch.notifier))
+		}
+	}
+
+	// Articles left from a failed delivery are only retried where they are missing, so there is
+	// nothing to report if every destination has them all, e.g. because the failing one has
+	// since been removed. They can be marked reported instead.
+	fresh, err := anyUndelivered(rec.Destinations, validArticles)
+	if err != nil {
+		return fmt.Errorf("error fetching deliveries: %v", err)
+	}
+	if !fresh {
+		log.Println("Every destination already has these articles. Skipping report.")
+		if err := DB.MarkArticlesReported(reportGUIDs(validArticles)); err != nil {
+			return fmt.Errorf("error marking articles as reported: %v", err)
+		}
+		return nil
+	}
+
+	build, err := newReportBuilder(rec)
+	if err != nil {
+		return err
+	}
+
+	// Keep the rendered report, so it can be browsed later in the web UI.
+	rec.HTML, err = build(validArticles).Generate(report.FORMAT_HTML)
+	if err != nil {
+		return fmt.Errorf("error rendering report: %v", err)
+	}
+
+	if err := DB.CreateReport(rec, validArticles); err != nil {
+		return fmt.Errorf("error saving report: %v", err)
 	}
 
-	rep := report.NewReport(title, validArticles)
+	var errs []error
 
-	// Write to file
+	// Write to file, unless it already has every article
 	if reportOut != "" {
-		if err := rep.GenerateFile(reportOut); err != nil {
-			return fmt.Errorf("error writing report file: %v", err)
+		pending, err := undelivered([]string{fileDestination(reportOut)}, validArticles)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: error fetching deliveries: %v", reportOut, err))
+		case len(pending) == 0:
+			log.Printf("Nothing new for %s.", reportOut)
+			recordUpToDate(rec.ID, fileDestination(reportOut))
+		default:
+			if err := writeReportFile(rec.ID, reportOut, reportFormat, pending, build); err != nil {
+				log.Printf("Error writing report file: %v", err)
+				errs = append(errs, fmt.Errorf("%s: %v", reportOut, err))
+			}
 		}
 	}
 
 	// Send email
 	if reportSendEmail {
-		to := viper.GetString("email_to")
-		from := viper.GetString("email_from")
-		smarthost := viper.GetString("email_smarthost")
-		identity := viper.GetString("email_identity")
-		username := viper.GetString("email_username")
-		password := viper.GetString("email_password")
-
-		cfgSubject := viper.GetString("email_subject")
-		if cfgSubject != "" {
-			rep.Title = cfgSubject
-		}
+		errs = append(errs, deliverEmail(rec.ID, validArticles, build, viper.GetBool("email_per_recipient"))...)
+	}
 
-		log.Printf("Sending email to %s via %s...", to, smarthost)
-		if err := rep.GenerateEmail(to, from, smarthost, identity, username, password); err != nil {
-			return fmt.Errorf("error sending email: %v", err)
-		}
-		log.Println("Email sent successfully.")
+	// Send to chat channels
+	if reportNotify {
+		errs = append(errs, deliverNotifications(ctx, rec.ID, channels, validArticles, build)...)
+	}
+
+	log.Printf("Processed %d articles in report %d.", len(validArticles), rec.ID)
+
+	// Mark articles as reported, including those folded into a story, once every destination has them
+	return finishReport(rec, validArticles, errs)
+}
+
+// newReportBuilder returns a function that builds reports with the settings of the stored
+// report. Reports are built per recipient when sending them separately, so they only hold
+// what that recipient has not received yet.
+func newReportBuilder(rec *storage.Report) (reportBuilder, error) {
+	feedNames, err := DB.GetFeedNames()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed names: %v", err)
 	}
 
-	fmt.Printf("Processed %d articles.\n", len(validArticles))
+	return func(articles []storage.Article) *report.Report {
+		rep := report.NewReport(rec.Title, articles)
+		rep.Threshold = rec.Threshold
+		rep.AgeDays = rec.AgeDays
+		rep.FeedNames = feedNames
+		rep.TemplatePath = viper.GetString("report_template")
+		rep.Omitted = rec.Omitted
+		if rec.Omitted > 0 {
+			rep.MoreURL = viper.GetString("site_url")
+		}
+		rep.Sort(rec.SortOrder)
+		rep.Group(rec.GroupBy)
+		return rep
+	}, nil
+}
 
-	// Mark articles as reported
-	var guids []string
-	for _, art := range validArticles {
-		guids = append(guids, art.GUID)
+// runCheckTemplate renders the configured report template with sample data and reports
+// whether it worked.
+func runCheckTemplate() {
+	path := viper.GetString("report_template")
+	if err := report.CheckTemplate(path); err != nil {
+		fatalf("Report template is invalid: %v", err)
 	}
-	if err := DB.MarkArticlesReported(guids); err != nil {
-		return fmt.Errorf("error marking articles as reported: %v", err)
+	if path == "" {
+		path = "(built-in default)"
 	}
-	log.Printf("Marked %d articles as reported.", len(guids))
-	return nil
+	fmt.Printf("Report template %s is valid.\n", path)
 }
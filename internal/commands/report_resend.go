@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportListLimit int
+	reportResendAll bool
+)
+
+var reportListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recently generated reports and their delivery status",
+	Run: func(cmd *cobra.Command, args []string) {
+		runReportList()
+	},
+}
+
+var reportResendCmd = &cobra.Command{
+	Use:   "resend [id]",
+	Short: "Deliver a stored report again",
+	Long: `Deliver a stored report again, with the same articles. By default only destinations where
+delivery failed are retried; use --all to deliver to every destination. Email recipients are
+sent a separate message each.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fatalf("Invalid report id %q", args[0])
+		}
+		if err := resendReport(cmd.Context(), id); err != nil {
+			fatalf("Error resending report: %v", err)
+		}
+	},
+}
+
+func init() {
+	reportListCmd.Flags().IntVar(&reportListLimit, "limit", 20, "Number of reports to list")
+	reportResendCmd.Flags().BoolVar(&reportResendAll, "all", false, "Deliver to every destination, not just the ones that failed")
+	reportCmd.AddCommand(reportListCmd)
+	reportCmd.AddCommand(reportResendCmd)
+}
+
+func runReportList() {
+	reports, err := DB.ListReports(reportListLimit)
+	if err != nil {
+		fatalf("Error listing reports: %v", err)
+	}
+
+	for _, r := range reports {
+		fmt.Printf("%4d %s %-7s %3d articles  %s\n", r.ID, r.CreatedAt.Local().Format("2006-01-02 15:04"), r.Status, r.ArticleCount, strings.Join(r.Destinations, ", "))
+		if r.Error != "" {
+			fmt.Printf("     %s\n", r.Error)
+		}
+	}
+}
+
+// failedDestinations returns the destinations of a report whose most recent delivery
+// attempt failed, or that were never attempted.
+func failedDestinations(rec *storage.Report) ([]string, error) {
+	deliveries, err := DB.GetReportDeliveries(rec.ID)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]string)
+	for _, d := range deliveries {
+		latest[d.Destination] = d.Status
+	}
+
+	var failed []string
+	for _, dest := range rec.Destinations {
+		if latest[dest] != storage.DELIVERY_OK {
+			failed = append(failed, dest)
+		}
+	}
+	return failed, nil
+}
+
+func resendReport(ctx context.Context, id int64) error {
+	rec, err := DB.GetReport(id)
+	if err != nil {
+		return fmt.Errorf("error fetching report: %v", err)
+	}
+	if rec == nil {
+		return fmt.Errorf("report %d not found", id)
+	}
+
+	destinations := rec.Destinations
+	if !reportResendAll {
+		destinations, err = failedDestinations(rec)
+		if err != nil {
+			return fmt.Errorf("error fetching deliveries: %v", err)
+		}
+	}
+	if len(destinations) == 0 {
+		log.Printf("Report %d was delivered to every destination; use --all to send it again.", id)
+		return nil
+	}
+
+	articles, err := DB.GetReportArticles(id)
+	if err != nil {
+		return fmt.Errorf("error fetching report articles: %v", err)
+	}
+	if len(articles) == 0 {
+		return fmt.Errorf("report %d has no articles left in the database", id)
+	}
+
+	build, err := newReportBuilder(rec)
+	if err != nil {
+		return err
+	}
+
+	channels, err := notifyChannels()
+	if err != nil {
+		return fmt.Errorf("error configuring notifications: %v", err)
+	}
+
+	var errs []error
+	for _, dest := range destinations {
+		switch {
+		case strings.HasPrefix(dest, DESTINATION_FILE):
+			path := strings.TrimPrefix(dest, DESTINATION_FILE)
+			err = writeReportFile(rec.ID, path, rec.Format, articles, build)
+		case strings.HasPrefix(dest, DESTINATION_EMAIL):
+			msg := emailMessage()
+			msg.To, msg.Cc, msg.Bcc = []string{strings.TrimPrefix(dest, DESTINATION_EMAIL)}, nil, nil
+			log.Printf("Sending report %d to %s...", rec.ID, msg.To[0])
+			err = sendReportEmail(rec.ID, msg, articles, build)
+		case strings.HasPrefix(dest, DESTINATION_NOTIFY):
+			err = fmt.Errorf("notification channel is no longer configured")
+			for _, ch := range channels {
+				if notifyDestination(ch.notifier) == dest {
+					err = notifyChannel(ctx, rec.ID, ch, articles, build)
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown destination")
+		}
+		if err != nil {
+			log.Printf("Error delivering to %s: %v", dest, err)
+			errs = append(errs, fmt.Errorf("%s: %v", dest, err))
+		}
+	}
+
+	// Destinations that were not retried had already succeeded, so these are all the failures.
+	return finishReport(rec, articles, errs)
+}
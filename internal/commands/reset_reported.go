@@ -15,7 +15,7 @@ var resetReportedCmd = &cobra.Command{
 		pattern := args[0]
 		affected, err := DB.ResetReported(pattern)
 		if err != nil {
-			log.Fatalf("Error resetting reported flags: %v", err)
+			fatalf("Error resetting reported flags: %v", err)
 		}
 		log.Printf("Reset reported flag for %d articles matching '%s'", affected, pattern)
 	},
@@ -1,10 +1,17 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"github.com/scottmbaker/ai-rss-scraper/internal/htmlserver"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/email"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/notify"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/utils"
 	"github.com/spf13/cobra"
@@ -35,13 +42,27 @@ specific preferences. It stores the history in a local SQLite database.`,
 )
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Cancel the context on SIGINT or SIGTERM, so that long-running commands can finish or
+	// abort what they are doing and return, and the database is closed cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if DB != nil {
+		DB.Close()
+	}
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+}
+
+// fatalf closes the database, then logs the message and exits. Use it rather than
+// log.Fatalf once a command has started working with the database.
+func fatalf(format string, args ...any) {
 	if DB != nil {
 		DB.Close()
 	}
+	log.Fatalf(format, args...)
 }
 
 func init() {
@@ -54,13 +75,54 @@ func init() {
 	rootCmd.PersistentFlags().String("model", "gemini-3-flash", "LLM Model to use")
 	rootCmd.PersistentFlags().String("feed-url", "https://hackaday.com/blog/feed/", "RSS Feed URL")
 	rootCmd.PersistentFlags().String("prompt", "", "AI Prompt (string or @filename)")
+	rootCmd.PersistentFlags().String("translate-to", "", "Translate articles in other languages into this language (ISO 639-1 code, e.g. en)")
+	rootCmd.PersistentFlags().StringSlice("tag-vocabulary", nil, "Comma-separated list of allowed topic tags (default is free-form)")
+	rootCmd.PersistentFlags().String("report-template", "", "Report template file, or directory containing report.html (default is built-in)")
+	rootCmd.PersistentFlags().String("site-url", "", "Base URL of the web interface, used for links in reports (e.g. https://news.example.com/)")
 	rootCmd.PersistentFlags().String("email-smarthost", "", "SMTP Smarthost (hostname:port)")
+	rootCmd.PersistentFlags().String("email-security", email.SECURITY_STARTTLS, "Email transport security: starttls, starttls-required, tls (implicit), or none")
+	rootCmd.PersistentFlags().String("email-auth", "", "Email auth mechanism: plain, login, cram-md5, or none (default is plain if a username is set)")
+	rootCmd.PersistentFlags().String("email-ca-file", "", "PEM file of certificate authorities to trust for the SMTP server")
+	rootCmd.PersistentFlags().Duration("email-timeout", email.DEFAULT_TIMEOUT, "Timeout for talking to the SMTP server")
 	rootCmd.PersistentFlags().String("email-identity", "", "Email Identity (Auth Username)")
 	rootCmd.PersistentFlags().String("email-username", "", "Email Username")
 	rootCmd.PersistentFlags().String("email-password", "", "Email Password")
-	rootCmd.PersistentFlags().String("email-to", "", "Email To Address")
+	rootCmd.PersistentFlags().StringSlice("email-to", nil, "Email To Addresses (comma-separated)")
+	rootCmd.PersistentFlags().StringSlice("email-cc", nil, "Email Cc Addresses (comma-separated)")
+	rootCmd.PersistentFlags().StringSlice("email-bcc", nil, "Email Bcc Addresses (comma-separated)")
+	rootCmd.PersistentFlags().Bool("email-per-recipient", false, "Send a separate email to each recipient, tracking delivery for each")
 	rootCmd.PersistentFlags().String("email-from", "", "Email From Address")
 	rootCmd.PersistentFlags().String("email-subject", "rss article scrape results", "Email Subject")
+	rootCmd.PersistentFlags().String("email-unsubscribe", "", "Email List-Unsubscribe target (mailto: or https: URL)")
+	rootCmd.PersistentFlags().String("notify-slack-url", "", "Slack incoming webhook URL")
+	rootCmd.PersistentFlags().Int("notify-slack-threshold", 0, "Minimum score of articles sent to Slack (default is the report threshold)")
+	rootCmd.PersistentFlags().String("notify-discord-url", "", "Discord webhook URL")
+	rootCmd.PersistentFlags().Int("notify-discord-threshold", 0, "Minimum score of articles sent to Discord (default is the report threshold)")
+	rootCmd.PersistentFlags().String("notify-matrix-homeserver", "", "Matrix homeserver URL (e.g. https://matrix.org)")
+	rootCmd.PersistentFlags().String("notify-matrix-room", "", "Matrix room ID to post to")
+	rootCmd.PersistentFlags().String("notify-matrix-token", "", "Matrix access token")
+	rootCmd.PersistentFlags().Int("notify-matrix-threshold", 0, "Minimum score of articles sent to Matrix (default is the report threshold)")
+	rootCmd.PersistentFlags().String("notify-webhook-url", "", "Generic webhook URL, sent the report as JSON")
+	rootCmd.PersistentFlags().String("notify-webhook-template", "", "Body template for the generic webhook (string or @filename)")
+	rootCmd.PersistentFlags().Int("notify-webhook-threshold", 0, "Minimum score of articles sent to the generic webhook (default is the report threshold)")
+	rootCmd.PersistentFlags().Int("notify-retries", notify.DEFAULT_RETRIES, "Number of times to retry a failed notification")
+	rootCmd.PersistentFlags().Duration("notify-timeout", notify.DEFAULT_TIMEOUT, "Timeout for each request to a notification channel")
+	rootCmd.PersistentFlags().Int("alert-threshold", DEFAULT_ALERT_THRESHOLD, "Minimum score for an instant push alert when an article is scored")
+	rootCmd.PersistentFlags().String("alert-ntfy-url", "", "ntfy topic URL for instant alerts (e.g. https://ntfy.sh/mytopic)")
+	rootCmd.PersistentFlags().String("alert-ntfy-token", "", "ntfy access token, if the topic requires one")
+	rootCmd.PersistentFlags().String("alert-gotify-url", "", "Gotify server URL for instant alerts")
+	rootCmd.PersistentFlags().String("alert-gotify-token", "", "Gotify application token")
+	rootCmd.PersistentFlags().String("schedule-fetch", "", "Cron expression for fetching articles under run (e.g. \"0 * * * *\")")
+	rootCmd.PersistentFlags().String("schedule-score", "", "Cron expression for scoring articles under run")
+	rootCmd.PersistentFlags().String("schedule-report", "", "Cron expression for generating the report under run (e.g. \"0 7 * * 1-5\")")
+	rootCmd.PersistentFlags().String("schedule-prune", "", "Cron expression for pruning old articles under run")
+	rootCmd.PersistentFlags().String("schedule-timezone", "", "Timezone the schedules are evaluated in (e.g. Europe/Berlin; default is local time)")
+	rootCmd.PersistentFlags().Int("prune-days", DEFAULT_PRUNE_DAYS, "Age in days after which articles are deleted by prune")
+	rootCmd.PersistentFlags().String("auth-mode", htmlserver.AUTH_NONE, "Authentication for the web interface: "+strings.Join(htmlserver.AUTH_MODES, ", "))
+	rootCmd.PersistentFlags().StringSlice("auth-users", nil, "Users for basic auth, as name:bcrypt-hash (comma-separated; see hash-password)")
+	rootCmd.PersistentFlags().StringSlice("auth-tokens", nil, "Bearer tokens accepted for the JSON API (comma-separated)")
+	rootCmd.PersistentFlags().String("auth-header", htmlserver.DEFAULT_AUTH_HEADER, "Header carrying the user name set by the auth proxy, in header mode")
+	rootCmd.PersistentFlags().StringSlice("auth-trusted-proxies", nil, "Addresses or CIDR ranges of the auth proxies trusted in header mode (default is localhost only)")
 
 	// Ckerr to make linter happy... is there any real chance of these failing??
 
@@ -70,13 +132,54 @@ func init() {
 	utils.Ckerr(viper.BindPFlag("model", rootCmd.PersistentFlags().Lookup("model")))
 	utils.Ckerr(viper.BindPFlag("feed_url", rootCmd.PersistentFlags().Lookup("feed-url")))
 	utils.Ckerr(viper.BindPFlag("prompt", rootCmd.PersistentFlags().Lookup("prompt")))
+	utils.Ckerr(viper.BindPFlag("translate_to", rootCmd.PersistentFlags().Lookup("translate-to")))
+	utils.Ckerr(viper.BindPFlag("tag_vocabulary", rootCmd.PersistentFlags().Lookup("tag-vocabulary")))
+	utils.Ckerr(viper.BindPFlag("report_template", rootCmd.PersistentFlags().Lookup("report-template")))
+	utils.Ckerr(viper.BindPFlag("site_url", rootCmd.PersistentFlags().Lookup("site-url")))
 	utils.Ckerr(viper.BindPFlag("email_smarthost", rootCmd.PersistentFlags().Lookup("email-smarthost")))
+	utils.Ckerr(viper.BindPFlag("email_security", rootCmd.PersistentFlags().Lookup("email-security")))
+	utils.Ckerr(viper.BindPFlag("email_auth", rootCmd.PersistentFlags().Lookup("email-auth")))
+	utils.Ckerr(viper.BindPFlag("email_ca_file", rootCmd.PersistentFlags().Lookup("email-ca-file")))
+	utils.Ckerr(viper.BindPFlag("email_timeout", rootCmd.PersistentFlags().Lookup("email-timeout")))
 	utils.Ckerr(viper.BindPFlag("email_identity", rootCmd.PersistentFlags().Lookup("email-identity")))
 	utils.Ckerr(viper.BindPFlag("email_username", rootCmd.PersistentFlags().Lookup("email-username")))
 	utils.Ckerr(viper.BindPFlag("email_password", rootCmd.PersistentFlags().Lookup("email-password")))
 	utils.Ckerr(viper.BindPFlag("email_to", rootCmd.PersistentFlags().Lookup("email-to")))
+	utils.Ckerr(viper.BindPFlag("email_cc", rootCmd.PersistentFlags().Lookup("email-cc")))
+	utils.Ckerr(viper.BindPFlag("email_bcc", rootCmd.PersistentFlags().Lookup("email-bcc")))
+	utils.Ckerr(viper.BindPFlag("email_per_recipient", rootCmd.PersistentFlags().Lookup("email-per-recipient")))
 	utils.Ckerr(viper.BindPFlag("email_from", rootCmd.PersistentFlags().Lookup("email-from")))
 	utils.Ckerr(viper.BindPFlag("email_subject", rootCmd.PersistentFlags().Lookup("email-subject")))
+	utils.Ckerr(viper.BindPFlag("email_unsubscribe", rootCmd.PersistentFlags().Lookup("email-unsubscribe")))
+	utils.Ckerr(viper.BindPFlag("notify_slack_url", rootCmd.PersistentFlags().Lookup("notify-slack-url")))
+	utils.Ckerr(viper.BindPFlag("notify_slack_threshold", rootCmd.PersistentFlags().Lookup("notify-slack-threshold")))
+	utils.Ckerr(viper.BindPFlag("notify_discord_url", rootCmd.PersistentFlags().Lookup("notify-discord-url")))
+	utils.Ckerr(viper.BindPFlag("notify_discord_threshold", rootCmd.PersistentFlags().Lookup("notify-discord-threshold")))
+	utils.Ckerr(viper.BindPFlag("notify_matrix_homeserver", rootCmd.PersistentFlags().Lookup("notify-matrix-homeserver")))
+	utils.Ckerr(viper.BindPFlag("notify_matrix_room", rootCmd.PersistentFlags().Lookup("notify-matrix-room")))
+	utils.Ckerr(viper.BindPFlag("notify_matrix_token", rootCmd.PersistentFlags().Lookup("notify-matrix-token")))
+	utils.Ckerr(viper.BindPFlag("notify_matrix_threshold", rootCmd.PersistentFlags().Lookup("notify-matrix-threshold")))
+	utils.Ckerr(viper.BindPFlag("notify_webhook_url", rootCmd.PersistentFlags().Lookup("notify-webhook-url")))
+	utils.Ckerr(viper.BindPFlag("notify_webhook_template", rootCmd.PersistentFlags().Lookup("notify-webhook-template")))
+	utils.Ckerr(viper.BindPFlag("notify_webhook_threshold", rootCmd.PersistentFlags().Lookup("notify-webhook-threshold")))
+	utils.Ckerr(viper.BindPFlag("notify_retries", rootCmd.PersistentFlags().Lookup("notify-retries")))
+	utils.Ckerr(viper.BindPFlag("notify_timeout", rootCmd.PersistentFlags().Lookup("notify-timeout")))
+	utils.Ckerr(viper.BindPFlag("alert_threshold", rootCmd.PersistentFlags().Lookup("alert-threshold")))
+	utils.Ckerr(viper.BindPFlag("alert_ntfy_url", rootCmd.PersistentFlags().Lookup("alert-ntfy-url")))
+	utils.Ckerr(viper.BindPFlag("alert_ntfy_token", rootCmd.PersistentFlags().Lookup("alert-ntfy-token")))
+	utils.Ckerr(viper.BindPFlag("alert_gotify_url", rootCmd.PersistentFlags().Lookup("alert-gotify-url")))
+	utils.Ckerr(viper.BindPFlag("alert_gotify_token", rootCmd.PersistentFlags().Lookup("alert-gotify-token")))
+	utils.Ckerr(viper.BindPFlag("schedule_fetch", rootCmd.PersistentFlags().Lookup("schedule-fetch")))
+	utils.Ckerr(viper.BindPFlag("schedule_score", rootCmd.PersistentFlags().Lookup("schedule-score")))
+	utils.Ckerr(viper.BindPFlag("schedule_report", rootCmd.PersistentFlags().Lookup("schedule-report")))
+	utils.Ckerr(viper.BindPFlag("schedule_prune", rootCmd.PersistentFlags().Lookup("schedule-prune")))
+	utils.Ckerr(viper.BindPFlag("schedule_timezone", rootCmd.PersistentFlags().Lookup("schedule-timezone")))
+	utils.Ckerr(viper.BindPFlag("prune_days", rootCmd.PersistentFlags().Lookup("prune-days")))
+	utils.Ckerr(viper.BindPFlag("auth_mode", rootCmd.PersistentFlags().Lookup("auth-mode")))
+	utils.Ckerr(viper.BindPFlag("auth_users", rootCmd.PersistentFlags().Lookup("auth-users")))
+	utils.Ckerr(viper.BindPFlag("auth_tokens", rootCmd.PersistentFlags().Lookup("auth-tokens")))
+	utils.Ckerr(viper.BindPFlag("auth_header", rootCmd.PersistentFlags().Lookup("auth-header")))
+	utils.Ckerr(viper.BindPFlag("auth_trusted_proxies", rootCmd.PersistentFlags().Lookup("auth-trusted-proxies")))
 
 	utils.Ckerr(viper.BindEnv("db_path", "DB_PATH"))
 	utils.Ckerr(viper.BindEnv("api_key", "API_KEY"))
@@ -84,13 +187,54 @@ func init() {
 	utils.Ckerr(viper.BindEnv("model", "MODEL"))
 	utils.Ckerr(viper.BindEnv("feed_url", "FEED_URL"))
 	utils.Ckerr(viper.BindEnv("prompt", "PROMPT"))
+	utils.Ckerr(viper.BindEnv("translate_to", "TRANSLATE_TO"))
+	utils.Ckerr(viper.BindEnv("tag_vocabulary", "TAG_VOCABULARY"))
+	utils.Ckerr(viper.BindEnv("report_template", "REPORT_TEMPLATE"))
+	utils.Ckerr(viper.BindEnv("site_url", "SITE_URL"))
 	utils.Ckerr(viper.BindEnv("email_smarthost", "EMAIL_SMARTHOST"))
+	utils.Ckerr(viper.BindEnv("email_security", "EMAIL_SECURITY"))
+	utils.Ckerr(viper.BindEnv("email_auth", "EMAIL_AUTH"))
+	utils.Ckerr(viper.BindEnv("email_ca_file", "EMAIL_CA_FILE"))
+	utils.Ckerr(viper.BindEnv("email_timeout", "EMAIL_TIMEOUT"))
 	utils.Ckerr(viper.BindEnv("email_identity", "EMAIL_IDENTITY"))
 	utils.Ckerr(viper.BindEnv("email_username", "EMAIL_USERNAME"))
 	utils.Ckerr(viper.BindEnv("email_password", "EMAIL_PASSWORD"))
 	utils.Ckerr(viper.BindEnv("email_to", "EMAIL_TO"))
+	utils.Ckerr(viper.BindEnv("email_cc", "EMAIL_CC"))
+	utils.Ckerr(viper.BindEnv("email_bcc", "EMAIL_BCC"))
+	utils.Ckerr(viper.BindEnv("email_per_recipient", "EMAIL_PER_RECIPIENT"))
 	utils.Ckerr(viper.BindEnv("email_from", "EMAIL_FROM"))
 	utils.Ckerr(viper.BindEnv("email_subject", "EMAIL_SUBJECT"))
+	utils.Ckerr(viper.BindEnv("email_unsubscribe", "EMAIL_UNSUBSCRIBE"))
+	utils.Ckerr(viper.BindEnv("notify_slack_url", "NOTIFY_SLACK_URL"))
+	utils.Ckerr(viper.BindEnv("notify_slack_threshold", "NOTIFY_SLACK_THRESHOLD"))
+	utils.Ckerr(viper.BindEnv("notify_discord_url", "NOTIFY_DISCORD_URL"))
+	utils.Ckerr(viper.BindEnv("notify_discord_threshold", "NOTIFY_DISCORD_THRESHOLD"))
+	utils.Ckerr(viper.BindEnv("notify_matrix_homeserver", "NOTIFY_MATRIX_HOMESERVER"))
+	utils.Ckerr(viper.BindEnv("notify_matrix_room", "NOTIFY_MATRIX_ROOM"))
+	utils.Ckerr(viper.BindEnv("notify_matrix_token", "NOTIFY_MATRIX_TOKEN"))
+	utils.Ckerr(viper.BindEnv("notify_matrix_threshold", "NOTIFY_MATRIX_THRESHOLD"))
+	utils.Ckerr(viper.BindEnv("notify_webhook_url", "NOTIFY_WEBHOOK_URL"))
+	utils.Ckerr(viper.BindEnv("notify_webhook_template", "NOTIFY_WEBHOOK_TEMPLATE"))
+	utils.Ckerr(viper.BindEnv("notify_webhook_threshold", "NOTIFY_WEBHOOK_THRESHOLD"))
+	utils.Ckerr(viper.BindEnv("notify_retries", "NOTIFY_RETRIES"))
+	utils.Ckerr(viper.BindEnv("notify_timeout", "NOTIFY_TIMEOUT"))
+	utils.Ckerr(viper.BindEnv("alert_threshold", "ALERT_THRESHOLD"))
+	utils.Ckerr(viper.BindEnv("alert_ntfy_url", "ALERT_NTFY_URL"))
+	utils.Ckerr(viper.BindEnv("alert_ntfy_token", "ALERT_NTFY_TOKEN"))
+	utils.Ckerr(viper.BindEnv("alert_gotify_url", "ALERT_GOTIFY_URL"))
+	utils.Ckerr(viper.BindEnv("alert_gotify_token", "ALERT_GOTIFY_TOKEN"))
+	utils.Ckerr(viper.BindEnv("schedule_fetch", "SCHEDULE_FETCH"))
+	utils.Ckerr(viper.BindEnv("schedule_score", "SCHEDULE_SCORE"))
+	utils.Ckerr(viper.BindEnv("schedule_report", "SCHEDULE_REPORT"))
+	utils.Ckerr(viper.BindEnv("schedule_prune", "SCHEDULE_PRUNE"))
+	utils.Ckerr(viper.BindEnv("schedule_timezone", "SCHEDULE_TIMEZONE"))
+	utils.Ckerr(viper.BindEnv("prune_days", "PRUNE_DAYS"))
+	utils.Ckerr(viper.BindEnv("auth_mode", "AUTH_MODE"))
+	utils.Ckerr(viper.BindEnv("auth_users", "AUTH_USERS"))
+	utils.Ckerr(viper.BindEnv("auth_tokens", "AUTH_TOKENS"))
+	utils.Ckerr(viper.BindEnv("auth_header", "AUTH_HEADER"))
+	utils.Ckerr(viper.BindEnv("auth_trusted_proxies", "AUTH_TRUSTED_PROXIES"))
 
 	rootCmd.AddCommand(fetchCmd)
 	rootCmd.AddCommand(scoreCmd)
@@ -101,6 +245,12 @@ func init() {
 	rootCmd.AddCommand(reportCmd)
 	rootCmd.AddCommand(resetReportedCmd)
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(testEmailCmd)
+	rootCmd.AddCommand(deliveriesCmd)
+	rootCmd.AddCommand(testNotifyCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(hashPasswordCmd)
+	rootCmd.AddCommand(searchCmd)
 }
 
 func initConfig() {
@@ -121,7 +271,8 @@ func initConfig() {
 	}
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// Print to stderr, so that reports written to stdout are not disturbed.
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	} else if _, ok := err.(viper.ConfigFileNotFoundError); ok && usingDefaultConfig {
 		// No default config file found, that's fine.
 	} else {
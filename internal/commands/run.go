@@ -1,11 +1,19 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/scottmbaker/ai-rss-scraper/internal/htmlserver"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/schedule"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/story"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -14,7 +22,7 @@ var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Fetch articles and then score them",
 	Run: func(cmd *cobra.Command, args []string) {
-		runScraper()
+		runScraper(cmd.Context())
 	},
 }
 
@@ -25,71 +33,252 @@ var noReport bool
 
 var runServe bool
 
+var runRetries int
+var runRetryBackoff time.Duration
+var runFailureBudget int
+
+// MAX_RETRY_BACKOFF is the longest delay between retries of a failed stage.
+const MAX_RETRY_BACKOFF = 10 * time.Minute
+
+// DEFAULT_FAILURE_BUDGET is the default number of consecutive failures of a stage after
+// which run exits.
+const DEFAULT_FAILURE_BUDGET = 5
+
 func init() {
 	runCmd.Flags().IntVar(&reportAge, "age", 7, "Age of articles in days to include in report")
 	runCmd.Flags().IntVar(&reportThreshold, "threshold", 50, "Score threshold for report")
 	runCmd.Flags().StringVar(&reportOut, "out", "", "Output filename for the report")
+	runCmd.Flags().StringVar(&reportFormat, "format", report.FORMAT_HTML, "Report file format: "+strings.Join(report.FORMATS, ", "))
 	runCmd.Flags().BoolVar(&reportSendEmail, "send-email", false, "Send report via email")
+	runCmd.Flags().BoolVar(&reportNotify, "notify", false, "Send report to the configured chat notification channels")
+	runCmd.Flags().StringVar(&reportTag, "tag", "", "Only include articles with this topic tag in the report")
+	runCmd.Flags().BoolVar(&reportByTag, "by-tag", false, "Group the report into sections by topic tag")
+	utils.Ckerr(runCmd.Flags().MarkDeprecated("by-tag", "use --group-by tag instead"))
+	runCmd.Flags().StringVar(&reportGroupBy, "group-by", "", "Group the report into sections: "+strings.Join(report.GROUPS, ", "))
+	runCmd.Flags().StringVar(&reportSort, "sort", report.SORT_DATE, "Order of the articles in the report: "+strings.Join(report.SORTS, ", "))
+	runCmd.Flags().IntVar(&reportMax, "max-articles", 0, "Include at most this many of the highest scoring articles (0 means no limit)")
+	runCmd.Flags().BoolVar(&reportStories, "stories", false, "Combine related articles about the same story into one report entry")
+	runCmd.Flags().Float64Var(&reportSimilarity, "story-similarity", story.DEFAULT_SIMILARITY, "Minimum similarity (0-1) for articles to be combined into a story")
 	runCmd.Flags().DurationVar(&runInterval, "interval", 0, "Interval to run the scraper loop (e.g. 1h, 30m). 0 means run once.")
 	runCmd.Flags().BoolVar(&noFetch, "no-fetch", false, "Don't fetch new articles")
 	runCmd.Flags().BoolVar(&noScore, "no-score", false, "Don't score articles")
 	runCmd.Flags().BoolVar(&noReport, "no-report", false, "Don't generate report")
+	runCmd.Flags().IntVar(&runRetries, "retries", 2, "Number of times to retry a failed fetch, score or report before moving on")
+	runCmd.Flags().DurationVar(&runRetryBackoff, "retry-backoff", 30*time.Second, "Delay before the first retry of a failed stage, doubling for each further retry")
+	runCmd.Flags().IntVar(&runFailureBudget, "failure-budget", DEFAULT_FAILURE_BUDGET, "Exit after a stage fails this many times in a row (0 means never)")
 
 	runCmd.Flags().BoolVar(&runServe, "serve", false, "Run the web server")
 	runCmd.Flags().StringVar(&serveHost, "host", "0.0.0.0", "Host interface to listen on")
 	runCmd.Flags().IntVar(&servePort, "port", 8080, "Port to listen on")
 }
 
+// scheduledJobs returns a job for each stage of the scraper, run by the runner. Those with
+// a cron expression configured run on it, evaluated in the configured timezone; the others
+// only run when triggered from the web interface.
+func scheduledJobs(r *runner) ([]schedule.Job, error) {
+	var location *time.Location
+	if tz := viper.GetString("schedule_timezone"); tz != "" {
+		var err error
+		location, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone: %v", err)
+		}
+	}
+
+	var jobs []schedule.Job
+	for _, s := range stages {
+		job := schedule.Job{
+			Name: s.name,
+			Run:  func(ctx context.Context, trigger string) error { return r.runStage(ctx, s.name, trigger) },
+		}
+		key := "schedule_" + s.name
+		if spec := viper.GetString(key); spec != "" {
+			sched, err := schedule.Parse(spec, location)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			job.Schedule = sched
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// fetchStage fetches new articles from the configured and subscribed feeds.
+func fetchStage(ctx context.Context) error {
+	return fetchFeeds(ctx, DB)
+}
+
+// scoreStage scores the articles that have not been scored yet.
+func scoreStage(ctx context.Context) error {
+	return scoreArticles(ctx, DB, "", false)
+}
+
+// pruneStage deletes articles older than the configured number of days.
+func pruneStage(ctx context.Context) error {
+	return pruneArticles(DB, viper.GetInt("prune_days"))
+}
+
+// sleep waits for the duration, and returns false if the context was cancelled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// budgetString describes the failure budget for the logs.
+func budgetString(budget int) string {
+	if budget <= 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(budget)
+}
+
 // runScraper is fetch + score + report, and can be set to run in a loop, forever.
-// Generally when something goes bad, we do a log.Fatalf() and bail. If we're running
-// in Kubernetes, then Kubernetes will restart us.
-func runScraper() {
+//
+// A stage that fails is retried a few times with backoff, and then the loop carries on with
+// the next stage; the web server started by --serve keeps running. The process only exits
+// once one stage has failed --failure-budget times in a row, so that Kubernetes can restart
+// us if something is persistently wrong. When running once, it exits with an error if any
+// stage failed.
+//
+// If any --schedule-* options are set, each of those jobs instead runs on its own cron
+// schedule, one at a time.
+//
+// With --serve, the web interface can also ask for a fetch, score or report to run now. The
+// run is queued, and happens between the stages of the loop or the scheduled jobs, never
+// alongside them.
+//
+// When the context is cancelled (on SIGINT or SIGTERM), the stage in progress is stopped,
+// the web server drains its requests, and runScraper returns.
+func runScraper(ctx context.Context) {
+	health := schedule.NewHealth(runFailureBudget)
+	r := newRunner(health)
+
+	jobs, err := scheduledJobs(r)
+	if err != nil {
+		fatalf("Error configuring schedules: %v", err)
+	}
+
+	var sched *schedule.Scheduler
+	if slices.ContainsFunc(jobs, func(job schedule.Job) bool { return job.Schedule != nil }) {
+		if runInterval != 0 {
+			fatalf("--interval cannot be combined with --schedule-* options")
+		}
+		if viper.GetString("schedule_report") != "" && !reportSendEmail && !reportNotify && reportOut == "" {
+			fatalf("A scheduled report needs --out, --send-email or --notify")
+		}
+		sched = schedule.New()
+		for _, job := range jobs {
+			sched.Add(job)
+		}
+		r.sched = sched
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var serverDone chan struct{}
 	if runServe {
 		server := htmlserver.NewServer(serveHost, servePort, DB)
+		server.FeedURL = viper.GetString("feed_url")
+		server.Scorer = webScorer{db: DB}
+		server.Auth, err = webAuth()
+		if err != nil {
+			fatalf("Error configuring authentication: %v", err)
+		}
+		server.Scheduler = sched
+		server.Health = health
+		server.Runner = r
+
+		restore, err := captureOutput(r.history)
+		if err != nil {
+			fatalf("Error capturing output: %v", err)
+		}
+		defer restore()
+
+		serverDone = make(chan struct{})
 		go func() {
-			if err := server.Start(); err != nil {
-				log.Fatalf("Error starting server: %v", err)
+			defer close(serverDone)
+			if err := server.Start(ctx); err != nil {
+				fatalf("Error starting server: %v", err)
 			}
 		}()
 		// Give the server a moment to start
 		time.Sleep(1 * time.Second)
 	}
+	// On the way out, stop the server and wait for it to finish the requests in progress.
+	defer func() {
+		cancel()
+		if serverDone != nil {
+			<-serverDone
+		}
+	}()
 
 	fmt.Println("Starting ai-rss-scraper...")
 
+	if sched != nil {
+		sched.OnFinish = func(schedule.JobStatus) {
+			if _, exhausted := health.Exhausted(); exhausted {
+				cancel()
+			}
+		}
+		sched.Run(ctx)
+		giveUp(health)
+		log.Println("ai-rss-scraper finished")
+		return
+	}
+
 	for {
-		log.Println("Starting cycle...") // Added: Log for cycle start
+		log.Println("Starting cycle...")
+		failed := false
 		if !noFetch {
-			if err := fetchAndSave(DB, viper.GetString("feed_url")); err != nil {
-				log.Fatalf("Error fetching feed: %v", err)
-			}
+			failed = r.runStage(ctx, "fetch", schedule.TRIGGER_INTERVAL) != nil || failed
 		}
 
 		if !noScore {
-			if err := scoreArticles(DB, "", false); err != nil {
-				log.Fatalf("Error scoring articles: %v", err)
-			}
+			failed = r.runStage(ctx, "score", schedule.TRIGGER_INTERVAL) != nil || failed
 		}
 
 		if !noReport {
-			if err := generateReport(); err != nil {
-				log.Fatalf("Error running report: %v", err)
-			}
+			failed = r.runStage(ctx, "report", schedule.TRIGGER_INTERVAL) != nil || failed
 		}
 
+		if ctx.Err() != nil {
+			break
+		}
+		giveUp(health)
+
 		if runInterval == 0 {
-			// If --serve has been used, but runInterval is 0, then block this loop forever
-			// and keep serving in the background.
+			// If --serve has been used, but runInterval is 0, then block this loop until
+			// we are told to stop, and keep serving in the background, running whatever
+			// the web interface asks for.
 			if runServe {
-				select {}
+				r.wait(ctx, 0)
+			} else if failed {
+				fatalf("ai-rss-scraper finished with errors")
 			}
 			// Here is where we exit if runInterval == 0
 			break
 		}
 
 		log.Printf("Sleeping for %v...", runInterval)
-		time.Sleep(runInterval)
+		if !r.wait(ctx, runInterval) {
+			break
+		}
 	}
 
 	log.Println("ai-rss-scraper finished")
 }
+
+// giveUp exits if a stage has used up the failure budget.
+func giveUp(health *schedule.Health) {
+	if stage, exhausted := health.Exhausted(); exhausted {
+		fatalf("Giving up: %s failed %d times in a row; last error: %s", stage.Name, stage.ConsecutiveFailures, stage.LastError)
+	}
+}
@@ -0,0 +1,211 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/schedule"
+)
+
+// MAX_RUN_HISTORY is the number of recent runs kept, with their output, for the schedule page.
+const MAX_RUN_HISTORY = 20
+
+// stages are the stages of the scraper that run can run, in the order a cycle runs them.
+var stages = []struct {
+	name string
+	run  func(ctx context.Context) error
+}{
+	{"fetch", fetchStage},
+	{"score", scoreStage},
+	{"report", generateReport},
+	{"prune", pruneStage},
+}
+
+// runner runs the stages of the scraper for run, one at a time, whether they are started by
+// the interval loop, a cron schedule or a request from the web interface. It records their
+// failures in health, and keeps a history of the recent runs and their output.
+type runner struct {
+	health  *schedule.Health
+	history *schedule.History
+
+	// sched, if set, runs the stages instead of the interval loop, and queues the requested
+	// runs itself.
+	sched *schedule.Scheduler
+
+	mu       sync.Mutex
+	pending  []string
+	running  string
+	requests chan string
+}
+
+func newRunner(health *schedule.Health) *runner {
+	return &runner{
+		health:   health,
+		history:  schedule.NewHistory(MAX_RUN_HISTORY),
+		requests: make(chan string, len(stages)),
+	}
+}
+
+// stage returns the function that runs the named stage, or nil if there is no such stage.
+func stage(name string) func(ctx context.Context) error {
+	for _, s := range stages {
+		if s.name == name {
+			return s.run
+		}
+	}
+	return nil
+}
+
+// runStage runs one stage of the scraper, retrying it with exponential backoff if it fails,
+// and records the outcome in health and the history. A stage interrupted by the context being
+// cancelled is neither retried nor counted as a failure.
+func (r *runner) runStage(ctx context.Context, name string, trigger string) error {
+	r.mu.Lock()
+	if trigger == schedule.TRIGGER_MANUAL {
+		r.pending = slices.DeleteFunc(r.pending, func(p string) bool { return p == name })
+	}
+	r.running = name
+	r.mu.Unlock()
+
+	id := r.history.Start(name, trigger)
+	err := r.retry(ctx, name, stage(name))
+	r.history.Finish(id, err)
+
+	r.mu.Lock()
+	r.running = ""
+	r.mu.Unlock()
+	return err
+}
+
+// retry runs the stage, retrying it if it fails.
+func (r *runner) retry(ctx context.Context, name string, run func(ctx context.Context) error) error {
+	delay := runRetryBackoff
+	var err error
+	for attempt := 1; attempt <= runRetries+1; attempt++ {
+		if err = run(ctx); err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			log.Printf("Stopped %s: %v", name, err)
+			return err
+		}
+		if attempt > runRetries {
+			break
+		}
+		log.Printf("Error in %s (attempt %d of %d): %v; retrying in %v", name, attempt, runRetries+1, err, delay)
+		if !sleep(ctx, delay) {
+			return ctx.Err()
+		}
+		delay = min(delay*2, MAX_RETRY_BACKOFF)
+	}
+
+	stage := r.health.Record(name, err)
+	if err != nil {
+		log.Printf("Error in %s: %v (%d consecutive failures, failure budget %s)", name, err, stage.ConsecutiveFailures, budgetString(r.health.Budget()))
+	}
+	return err
+}
+
+// Trigger queues a run of the named stage. The run happens after the stage in progress, if
+// any, has finished, so it never overlaps with the interval loop or the scheduled jobs.
+// A stage that is already queued or running is not queued again.
+func (r *runner) Trigger(name string) error {
+	if stage(name) == nil {
+		return fmt.Errorf("%s: %w", name, schedule.ErrUnknownJob)
+	}
+	if r.sched != nil {
+		return r.sched.Trigger(name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running == name || slices.Contains(r.pending, name) {
+		return fmt.Errorf("%s: %w", name, schedule.ErrAlreadyQueued)
+	}
+	r.pending = append(r.pending, name)
+	r.requests <- name
+	log.Printf("Queued %s, requested manually", name)
+	return nil
+}
+
+// Pending returns the names of the stages queued to run.
+func (r *runner) Pending() []string {
+	if r.sched != nil {
+		var pending []string
+		for _, job := range r.sched.Jobs() {
+			if job.Queued {
+				pending = append(pending, job.Name)
+			}
+		}
+		return pending
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.pending)
+}
+
+// History returns the recent runs, most recent first.
+func (r *runner) History() []schedule.Run {
+	return r.history.Runs()
+}
+
+// wait runs the requested stages as they come in, until the duration has passed, or until the
+// context is cancelled if the duration is 0. It returns false if the context was cancelled.
+func (r *runner) wait(ctx context.Context, d time.Duration) bool {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timeout:
+			return true
+		case name := <-r.requests:
+			log.Printf("Running %s (%s)...", name, schedule.TRIGGER_MANUAL)
+			_ = r.runStage(ctx, name, schedule.TRIGGER_MANUAL)
+			if ctx.Err() != nil {
+				return false
+			}
+			giveUp(r.health)
+		}
+	}
+}
+
+// captureOutput copies everything written to the log and to stdout into w as well, until the
+// returned function is called.
+func captureOutput(w io.Writer) (func(), error) {
+	stdout := os.Stdout
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(io.MultiWriter(stdout, w), pr)
+	}()
+
+	os.Stdout = pw
+	log.SetOutput(io.MultiWriter(os.Stderr, w))
+
+	return func() {
+		log.SetOutput(os.Stderr)
+		os.Stdout = stdout
+		pw.Close()
+		<-done
+		pr.Close()
+	}, nil
+}
@@ -11,6 +11,8 @@ import (
 	"text/template"
 
 	openai "github.com/sashabaranov/go-openai"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/language"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/notify"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/utils"
 	"github.com/spf13/cobra"
@@ -24,6 +26,9 @@ var (
 
 const MAX_AI_CONTENT_LENGTH = 4096
 
+// MAX_TAGS is the maximum number of topic tags stored per article.
+const MAX_TAGS = 5
+
 // defaultPromptTemplate is the default prompt, designed for my needs.
 // You can override it with the --prompt flag or related environment variable.
 const defaultPromptTemplate = "Scott likes projects relating to vintage computers and speech synthesizers. " +
@@ -35,11 +40,17 @@ const defaultPromptTemplate = "Scott likes projects relating to vintage computer
 	"based on how much scott will like this project, please exactly three bullet points on what he will like.\n\n" +
 	"Title: {{.Title}}\nDescription: {{.Description}}\nContent: {{.Content}}"
 
+// tagPromptSuffix is appended to every prompt so that the model also produces topic tags.
+// It is a template so that the configured vocabulary, if any, can be included.
+const tagPromptSuffix = "\n\nFinally, on a line of its own, list up to {{.MaxTags}} short lowercase topic tags " +
+	"for the article in the form \"Tags: tag1, tag2, tag3\"." +
+	"{{if .TagVocabulary}} Choose tags only from this list: {{.TagVocabulary}}.{{end}}"
+
 var scoreCmd = &cobra.Command{
 	Use:   "score",
 	Short: "Score unscored articles in DB",
 	Run: func(cmd *cobra.Command, args []string) {
-		runScore()
+		runScore(cmd.Context())
 	},
 }
 
@@ -48,13 +59,15 @@ func init() {
 	scoreCmd.Flags().BoolVar(&showResponse, "showresponse", false, "Show the raw response from the model")
 }
 
-func runScore() {
-	if err := scoreArticles(DB, refreshPattern, showResponse); err != nil {
-		log.Fatalf("error scoring articles: %v", err)
+func runScore(ctx context.Context) {
+	if err := scoreArticles(ctx, DB, refreshPattern, showResponse); err != nil {
+		fatalf("error scoring articles: %v", err)
 	}
 }
 
-func scoreArticles(db *storage.DB, refreshPattern string, showResponse bool) error {
+// scoreArticles scores the articles that need it, one at a time. If the context is
+// cancelled, it stops after the article being scored and returns the context's error.
+func scoreArticles(ctx context.Context, db *storage.DB, refreshPattern string, showResponse bool) error {
 	articles, err := db.GetArticlesToScore(refreshPattern)
 	if err != nil {
 		return err
@@ -65,98 +78,246 @@ func scoreArticles(db *storage.DB, refreshPattern string, showResponse bool) err
 		return nil
 	}
 
-	client, err := NewAIClient()
+	promptTemplate, err := scoringPrompt(db)
+	if err != nil {
+		return err
+	}
+	sc, err := newScorer(db, promptTemplate, showResponse)
 	if err != nil {
 		return err
 	}
-	model := viper.GetString("model")
 
 	fmt.Printf("found %d unscored articles\n", len(articles))
 
+	for _, art := range articles {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := sc.score(ctx, art, sc.model); err != nil {
+			log.Printf("  Error scoring %s: %v", art.Title, err)
+		}
+	}
+	return nil
+}
+
+// scorer scores articles with the configured prompt.
+type scorer struct {
+	db           *storage.DB
+	client       *openai.Client
+	model        string
+	tmpl         *template.Template
+	vocabulary   []string
+	translateTo  string
+	pushers      []notify.Pusher
+	showResponse bool
+}
+
+// newScorer returns a scorer using the configured AI provider and model, and the prompt
+// template.
+func newScorer(db *storage.DB, promptTemplate string, showResponse bool) (*scorer, error) {
+	client, err := NewAIClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the template once
+	tmpl, err := template.New("prompt").Parse(promptTemplate + tagPromptSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing prompt template: %w", err)
+	}
+
+	// A misconfigured push service should not stop the scoring.
+	pushers, err := alertPushers()
+	if err != nil {
+		log.Printf("Error configuring instant alerts, they are disabled: %v", err)
+	}
+
+	return &scorer{
+		db:           db,
+		client:       client,
+		model:        viper.GetString("model"),
+		tmpl:         tmpl,
+		vocabulary:   tagVocabulary(),
+		translateTo:  viper.GetString("translate_to"),
+		pushers:      pushers,
+		showResponse: showResponse,
+	}, nil
+}
+
+// scoringPrompt returns the prompt template that articles are scored with: the version saved
+// from the web interface, if one is active, or the configured prompt.
+func scoringPrompt(db *storage.DB) (string, error) {
+	active, err := db.GetActivePrompt()
+	if err != nil {
+		return "", fmt.Errorf("error fetching the active prompt: %w", err)
+	}
+	if active != nil {
+		return active.Template, nil
+	}
+	return configuredPrompt()
+}
+
+// configuredPrompt returns the configured prompt template, reading it from a file if it is
+// given as @filename.
+func configuredPrompt() (string, error) {
 	promptTemplate := viper.GetString("prompt")
 	if promptTemplate == "" {
-		promptTemplate = defaultPromptTemplate
-	} else if strings.HasPrefix(promptTemplate, "@") {
+		return defaultPromptTemplate, nil
+	}
+	if strings.HasPrefix(promptTemplate, "@") {
 		filename := strings.TrimPrefix(promptTemplate, "@")
 		content, err := os.ReadFile(filename)
 		if err != nil {
-			return fmt.Errorf("error reading prompt file %s: %w", filename, err)
+			return "", fmt.Errorf("error reading prompt file %s: %w", filename, err)
 		}
-		promptTemplate = string(content)
+		return string(content), nil
 	}
+	return promptTemplate, nil
+}
 
-	// Parse the template once
-	tmpl, err := template.New("prompt").Parse(promptTemplate)
+// score scores the article with the model, translating it first if needed, and stores the
+// score, analysis and tags, along with the prompt, in the article's scoring history.
+func (sc *scorer) score(ctx context.Context, art storage.Article, model string) error {
+	fmt.Printf("Scoring: %s\n", art.Title)
+
+	if needsTranslation(art, sc.translateTo) {
+		title, summary, err := translateArticle(ctx, sc.client, model, art, sc.translateTo)
+		if err != nil {
+			log.Printf("  Error translating article: %v", err)
+		} else if err := sc.db.UpdateArticleTranslation(art.GUID, title, summary); err != nil {
+			log.Printf("  Error saving translation: %v", err)
+		} else {
+			fmt.Printf("  Translated from %s: %s\n", language.Name(art.Language), title)
+			art.TranslatedTitle = title
+			art.TranslatedSummary = summary
+		}
+	}
+
+	articlePrompt, content, err := sc.ask(ctx, art, model)
 	if err != nil {
-		return fmt.Errorf("error parsing prompt template: %w", err)
+		return err
+	}
+	if sc.showResponse {
+		fmt.Println("--------------------------------------------------------------------------------")
+		fmt.Println(content)
+		fmt.Println("--------------------------------------------------------------------------------")
 	}
 
-	scoreRegex := regexp.MustCompile(`(?i)(?:score|rating):\s*(\d+)`)
+	score := extractScore(content)
+	tags := extractTags(content, sc.vocabulary)
 
-	for _, art := range articles {
-		fmt.Printf("Scoring: %s\n", art.Title)
-
-		data := struct {
-			Title       string
-			Description string
-			Content     string
-		}{
-			Title:       art.Title,
-			Description: art.Description,
-			Content:     utils.TrimString(art.Content, MAX_AI_CONTENT_LENGTH),
-		}
+	fmt.Printf("  Score: %s\n", score)
+	if len(tags) > 0 {
+		fmt.Printf("  Tags: %s\n", strings.Join(tags, ", "))
+	}
 
-		var buf bytes.Buffer
-		if err := tmpl.Execute(&buf, data); err != nil {
-			log.Printf("  Error executing prompt template: %v", err)
-			continue
-		}
-		articlePrompt := buf.String()
-
-		resp, err := client.CreateChatCompletion(
-			context.Background(),
-			openai.ChatCompletionRequest{
-				Model: model,
-				Messages: []openai.ChatCompletionMessage{
-					{
-						Role:    openai.ChatMessageRoleUser,
-						Content: articlePrompt,
-					},
-				},
-			},
-		)
+	if err := sc.db.UpdateArticleScore(art.GUID, score, content, model, articlePrompt); err != nil {
+		return fmt.Errorf("error updating score: %w", err)
+	}
+	if err := sc.db.SetArticleTags(art.GUID, tags); err != nil {
+		log.Printf("Error updating tags for %s: %v", art.Title, err)
+	}
 
-		if err != nil {
-			log.Printf("  Error calling AI: %v", err)
-			continue
-		}
+	sendInstantAlert(ctx, sc.db, sc.pushers, art, score)
+	return nil
+}
 
-		content := resp.Choices[0].Message.Content
-		if showResponse {
-			fmt.Println("--------------------------------------------------------------------------------")
-			fmt.Println(content)
-			fmt.Println("--------------------------------------------------------------------------------")
-		}
+// ask sends the prompt for the article to the model, and returns the prompt and the model's
+// response.
+func (sc *scorer) ask(ctx context.Context, art storage.Article, model string) (string, string, error) {
+	// Score the translation when there is one, so that articles are judged consistently
+	// regardless of the language they were written in.
+	title, description := art.Title, art.Description
+	if art.TranslatedTitle != "" {
+		title, description = art.TranslatedTitle, art.TranslatedSummary
+	}
 
-		// Attempt to extract score
-		scoreMatch := scoreRegex.FindStringSubmatch(content)
-		score := "N/A"
-		if len(scoreMatch) > 1 {
-			score = scoreMatch[1]
-		} else {
-			// Fallback: find the first number in the text
-			fallbackRegex := regexp.MustCompile(`(\d+)`)
-			fallbackMatch := fallbackRegex.FindStringSubmatch(content)
-			if len(fallbackMatch) > 1 {
-				score = fallbackMatch[1]
+	data := struct {
+		Title         string
+		Description   string
+		Content       string
+		MaxTags       int
+		TagVocabulary string
+	}{
+		Title:         title,
+		Description:   description,
+		Content:       utils.TrimString(art.Content, MAX_AI_CONTENT_LENGTH),
+		MaxTags:       MAX_TAGS,
+		TagVocabulary: strings.Join(sc.vocabulary, ", "),
+	}
+
+	var buf bytes.Buffer
+	if err := sc.tmpl.Execute(&buf, data); err != nil {
+		return "", "", fmt.Errorf("error executing prompt template: %w", err)
+	}
+	articlePrompt := buf.String()
+
+	content, err := chatCompletion(ctx, sc.client, model, articlePrompt)
+	if err != nil {
+		return "", "", fmt.Errorf("error calling AI: %w", err)
+	}
+	return articlePrompt, content, nil
+}
+
+// extractScore finds the score in the model's response, or returns "N/A" if it has none.
+func extractScore(content string) string {
+	scoreRegex := regexp.MustCompile(`(?i)(?:score|rating):\s*(\d+)`)
+	if scoreMatch := scoreRegex.FindStringSubmatch(content); len(scoreMatch) > 1 {
+		return scoreMatch[1]
+	}
+
+	// Fallback: find the first number in the text
+	fallbackRegex := regexp.MustCompile(`(\d+)`)
+	if fallbackMatch := fallbackRegex.FindStringSubmatch(content); len(fallbackMatch) > 1 {
+		return fallbackMatch[1]
+	}
+	return "N/A"
+}
+
+// tagVocabulary returns the configured tag vocabulary, normalized. An empty
+// vocabulary means that the model may choose free-form tags.
+func tagVocabulary() []string {
+	var vocabulary []string
+	for _, entry := range viper.GetStringSlice("tag_vocabulary") {
+		// Entries from the environment arrive as a single comma-separated string.
+		for _, tag := range strings.Split(entry, ",") {
+			if tag = storage.NormalizeTag(tag); tag != "" {
+				vocabulary = append(vocabulary, tag)
 			}
 		}
+	}
+	return vocabulary
+}
 
-		fmt.Printf("  Score: %s\n", score)
+// extractTags finds the "Tags:" line in the model's response and returns up to MAX_TAGS
+// normalized tags. If a vocabulary is given, tags outside of it are dropped.
+func extractTags(content string, vocabulary []string) []string {
+	tagRegex := regexp.MustCompile(`(?im)^[\s*_#-]*tags[*_]*:\s*(.+)$`)
+	match := tagRegex.FindStringSubmatch(content)
+	if len(match) < 2 {
+		return nil
+	}
+
+	allowed := make(map[string]bool, len(vocabulary))
+	for _, tag := range vocabulary {
+		allowed[tag] = true
+	}
 
-		if err := db.UpdateArticleScore(art.GUID, score, content, model); err != nil {
-			log.Printf("Error updating score for %s: %v", art.Title, err)
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(match[1], ",") {
+		tag = storage.NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(allowed) > 0 && !allowed[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == MAX_TAGS {
+			break
 		}
 	}
-	return nil
+	return tags
 }
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"score line", "Score: 85\nAnalysis: a Z80 build.", "85"},
+		{"rating line", "Rating: 40", "40"},
+		{"case is ignored", "SCORE:72", "72"},
+		{"score after other numbers", "Found 3 Z80 boards.\nScore: 90", "90"},
+		{"first number as a fallback", "I would give this 65 out of 100.", "65"},
+		{"no number", "Not relevant.", "N/A"},
+		{"empty", "", "N/A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractScore(tt.content); got != tt.want {
+				t.Errorf("extractScore(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		vocabulary []string
+		want       []string
+	}{
+		{"tags line", "Score: 80\nTags: z80, retro, homebrew", nil, []string{"z80", "retro", "homebrew"}},
+		{"no tags line", "Score: 80\nA nice build.", nil, nil},
+		{"tags are normalized", "Tags: Z80, Retro Computing, \"CP/M\"", nil, []string{"z80", "retro-computing", "cp/m"}},
+		{"case of the label is ignored", "TAGS: z80", nil, []string{"z80"}},
+		{"markdown label", "**Tags:** z80, retro", nil, []string{"z80", "retro"}},
+		{"list item label", "- Tags: z80", nil, []string{"z80"}},
+		{"heading label", "## Tags: z80", nil, []string{"z80"}},
+		{"label must start the line", "Score: 80 Tags: z80", nil, nil},
+		{"duplicates are dropped", "Tags: z80, Z80, retro, z80", nil, []string{"z80", "retro"}},
+		{"empty tags are dropped", "Tags: z80, , **, retro", nil, []string{"z80", "retro"}},
+		{"at most MAX_TAGS", "Tags: a, b, c, d, e, f, g", nil, []string{"a", "b", "c", "d", "e"}},
+		{"vocabulary filters tags", "Tags: z80, politics, retro", []string{"retro", "z80"}, []string{"z80", "retro"}},
+		{"nothing in the vocabulary", "Tags: politics", []string{"z80"}, nil},
+		{"first tags line wins", "Tags: z80\nTags: retro", nil, []string{"z80"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractTags(tt.content, tt.vocabulary); !slices.Equal(got, tt.want) {
+				t.Errorf("extractTags(%q, %v) = %v, want %v", tt.content, tt.vocabulary, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/spf13/cobra"
+)
+
+var searchLimit int
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the titles, descriptions, content and analysis of stored articles",
+	Long: `Search the titles, descriptions, content and analysis of stored articles, listing the best
+matches first with an extract showing where each matched. Articles must contain every word of
+the query; a word ending in * matches any word starting with it, e.g. "z80*".`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSearch(strings.Join(args, " "))
+	},
+}
+
+func init() {
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "Maximum number of articles to list")
+}
+
+func runSearch(query string) {
+	results, err := DB.SearchArticles(query, searchLimit)
+	if err != nil {
+		fatalf("Error searching articles: %v", err)
+	}
+	if len(results) == 0 {
+		fmt.Println("No articles found.")
+		return
+	}
+
+	// Snippets of the content may hold HTML, and may cut it off part way through a tag.
+	p := bluemonday.StrictPolicy()
+	for _, result := range results {
+		printListLine(result.Article)
+		snippet := strings.Join(strings.Fields(html.UnescapeString(p.Sanitize(result.Snippet))), " ")
+		if snippet != "" {
+			fmt.Printf("      %s\n", snippet)
+		}
+		fmt.Printf("      %s\n", result.Link)
+	}
+}
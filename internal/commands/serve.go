@@ -1,15 +1,21 @@
 package commands
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"strings"
 
 	"github.com/scottmbaker/ai-rss-scraper/internal/htmlserver"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
-	serveHost string
-	servePort int
+	serveHost          string
+	servePort          int
+	serveFeedThreshold int
+	serveFeedAge       int
 )
 
 var serveCmd = &cobra.Command{
@@ -17,13 +23,111 @@ var serveCmd = &cobra.Command{
 	Short: "Start the web server interface",
 	Run: func(cmd *cobra.Command, args []string) {
 		server := htmlserver.NewServer(serveHost, servePort, DB)
-		if err := server.Start(); err != nil {
-			log.Fatalf("Error starting server: %v", err)
+		server.FeedThreshold = serveFeedThreshold
+		server.FeedAgeDays = serveFeedAge
+		server.FeedURL = viper.GetString("feed_url")
+		server.Scorer = webScorer{db: DB}
+		auth, err := webAuth()
+		if err != nil {
+			fatalf("Error configuring authentication: %v", err)
+		}
+		server.Auth = auth
+		if err := server.Start(cmd.Context()); err != nil {
+			fatalf("Error starting server: %v", err)
 		}
 	},
 }
 
+// webAuth returns the authentication configured for the web interface.
+func webAuth() (*htmlserver.Auth, error) {
+	return htmlserver.NewAuth(viper.GetString("auth_mode"), configList("auth_users"), configList("auth_tokens"),
+		viper.GetString("auth_header"), configList("auth_trusted_proxies"))
+}
+
+// webScorer scores articles on request from the web interface.
+type webScorer struct {
+	db *storage.DB
+}
+
+// Model returns the configured model.
+func (ws webScorer) Model() string {
+	return viper.GetString("model")
+}
+
+// Prompt returns the prompt template that articles are scored with.
+func (ws webScorer) Prompt() (string, error) {
+	return scoringPrompt(ws.db)
+}
+
+// ScoreArticle scores the article with the current prompt and the given model.
+func (ws webScorer) ScoreArticle(ctx context.Context, guid, model string) error {
+	art, err := ws.article(guid)
+	if err != nil {
+		return err
+	}
+	promptTemplate, err := scoringPrompt(ws.db)
+	if err != nil {
+		return err
+	}
+	sc, err := newScorer(ws.db, promptTemplate, false)
+	if err != nil {
+		return err
+	}
+	return sc.score(ctx, *art, model)
+}
+
+// TryPrompt scores the article with the prompt template and model, without storing the
+// result.
+func (ws webScorer) TryPrompt(ctx context.Context, promptTemplate, guid, model string) (htmlserver.PromptTrial, error) {
+	art, err := ws.article(guid)
+	if err != nil {
+		return htmlserver.PromptTrial{}, err
+	}
+	sc, err := newScorer(ws.db, promptTemplate, false)
+	if err != nil {
+		return htmlserver.PromptTrial{}, err
+	}
+	prompt, content, err := sc.ask(ctx, *art, model)
+	if err != nil {
+		return htmlserver.PromptTrial{}, err
+	}
+	return htmlserver.PromptTrial{
+		Prompt:   prompt,
+		Response: content,
+		Score:    extractScore(content),
+		Tags:     extractTags(content, sc.vocabulary),
+	}, nil
+}
+
+// article returns the article with the given GUID, or an error if there is none.
+func (ws webScorer) article(guid string) (*storage.Article, error) {
+	art, err := ws.db.GetArticle(guid)
+	if err != nil {
+		return nil, err
+	}
+	if art == nil {
+		return nil, fmt.Errorf("no article with guid %q", guid)
+	}
+	return art, nil
+}
+
+// configList returns the list configured under the given key. Entries from the environment
+// arrive as a single comma-separated string, so each entry is split on commas.
+func configList(key string) []string {
+	var list []string
+	for _, entry := range viper.GetStringSlice(key) {
+		for _, item := range strings.Split(entry, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
 func init() {
 	serveCmd.Flags().StringVar(&serveHost, "host", "0.0.0.0", "Host interface to listen on")
 	serveCmd.Flags().IntVar(&servePort, "port", 8080, "Port to listen on")
+	serveCmd.Flags().IntVar(&serveFeedThreshold, "feed-threshold", htmlserver.DEFAULT_FEED_THRESHOLD, "Default score threshold for articles in the published feeds")
+	serveCmd.Flags().IntVar(&serveFeedAge, "feed-age", htmlserver.DEFAULT_FEED_AGE_DAYS, "Default age in days of articles in the published feeds")
 }
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"log"
+	"net/mail"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/email"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var testEmailCmd = &cobra.Command{
+	Use:   "test-email",
+	Short: "Send a sample report to check the email configuration",
+	Run: func(cmd *cobra.Command, args []string) {
+		runTestEmail()
+	},
+}
+
+// emailConfig returns the SMTP transport configuration.
+func emailConfig() email.Config {
+	return email.Config{
+		Smarthost: viper.GetString("email_smarthost"),
+		Security:  viper.GetString("email_security"),
+		Auth:      viper.GetString("email_auth"),
+		Identity:  viper.GetString("email_identity"),
+		Username:  viper.GetString("email_username"),
+		Password:  viper.GetString("email_password"),
+		CAFile:    viper.GetString("email_ca_file"),
+		Timeout:   viper.GetDuration("email_timeout"),
+	}
+}
+
+// emailMessage returns a message with the configured sender, recipients and subject. The
+// body is left for the report to fill in.
+func emailMessage() email.Message {
+	return email.Message{
+		From:            viper.GetString("email_from"),
+		To:              emailAddresses("email_to"),
+		Cc:              emailAddresses("email_cc"),
+		Bcc:             emailAddresses("email_bcc"),
+		Subject:         viper.GetString("email_subject"),
+		ListUnsubscribe: viper.GetString("email_unsubscribe"),
+	}
+}
+
+// emailAddresses returns the list of addresses configured under the given key. Entries from
+// the environment arrive as a single comma-separated string, so each entry is parsed as an
+// address list; entries that fail to parse are kept as they are, for Send to report.
+func emailAddresses(key string) []string {
+	var addrs []string
+	for _, entry := range viper.GetStringSlice(key) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		list, err := mail.ParseAddressList(entry)
+		if err != nil {
+			addrs = append(addrs, strings.TrimSpace(entry))
+			continue
+		}
+		for _, a := range list {
+			if a.Name == "" {
+				addrs = append(addrs, a.Address)
+			} else {
+				addrs = append(addrs, a.String())
+			}
+		}
+	}
+	return addrs
+}
+
+func runTestEmail() {
+	cfg := emailConfig()
+	msg := emailMessage()
+	msg.Subject = "ai-rss-scraper test email"
+
+	rep := report.SampleReport()
+	rep.TemplatePath = viper.GetString("report_template")
+
+	log.Printf("Sending test email to %s via %s...", strings.Join(msg.Recipients(), ", "), cfg.Smarthost)
+	if err := rep.GenerateEmail(msg, cfg); err != nil {
+		fatalf("Error sending test email: %v", err)
+	}
+	log.Println("Test email sent successfully.")
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/language"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/utils"
+)
+
+// translatePrompt asks the model for a translated title and a short summary in a format
+// that is easy to parse back out of the response.
+const translatePrompt = "Translate the following %s article into %s. " +
+	"Reply with exactly two lines and nothing else:\n" +
+	"Title: <the translated title>\n" +
+	"Summary: <a translated summary of the article in two or three sentences>\n\n" +
+	"Title: %s\nDescription: %s\nContent: %s"
+
+// needsTranslation returns true if the article is in a known language other than the
+// target language, and has not been translated yet. An empty target disables translation.
+func needsTranslation(art storage.Article, target string) bool {
+	return target != "" && art.Language != "" && art.Language != target && art.TranslatedTitle == ""
+}
+
+// translateArticle uses the model to translate the article's title and summarize its
+// content in the target language.
+func translateArticle(ctx context.Context, client *openai.Client, model string, art storage.Article, target string) (string, string, error) {
+	prompt := fmt.Sprintf(translatePrompt, language.Name(art.Language), language.Name(target),
+		art.Title, art.Description, utils.TrimString(art.Content, MAX_AI_CONTENT_LENGTH))
+
+	content, err := chatCompletion(ctx, client, model, prompt)
+	if err != nil {
+		return "", "", err
+	}
+
+	titleMatch := regexp.MustCompile(`(?im)^\W*title\W*:\s*(.+)$`).FindStringSubmatch(content)
+	summaryMatch := regexp.MustCompile(`(?ims)^\W*summary\W*:\s*(.+)`).FindStringSubmatch(content)
+	if len(titleMatch) < 2 || len(summaryMatch) < 2 {
+		return "", "", fmt.Errorf("could not parse translation from response: %q", utils.TrimString(content, 200))
+	}
+
+	return strings.TrimSpace(titleMatch[1]), strings.TrimSpace(summaryMatch[1]), nil
+}
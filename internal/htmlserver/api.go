@@ -0,0 +1,376 @@
+package htmlserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/schedule"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// DEFAULT_API_LIMIT is the number of articles returned per page when the request does not say.
+const DEFAULT_API_LIMIT = 50
+
+// MAX_RATING is the highest rating that can be given to an article.
+const MAX_RATING = 5
+
+// APIError is the body of every error response from the API.
+type APIError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+// APIErrorDetail describes what went wrong. Status repeats the HTTP status code.
+type APIErrorDetail struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// APIArticle is an article as returned by the API. Score is null if the article has not been
+// scored, or the model's score was not a number.
+type APIArticle struct {
+	GUID              string    `json:"guid"`
+	Title             string    `json:"title"`
+	Link              string    `json:"link"`
+	Description       string    `json:"description"`
+	Content           string    `json:"content"`
+	PublishedDate     time.Time `json:"published_date"`
+	FeedURL           string    `json:"feed_url"`
+	Score             *int      `json:"score"`
+	Analysis          string    `json:"analysis"`
+	Model             string    `json:"model"`
+	Tags              []string  `json:"tags"`
+	Language          string    `json:"language"`
+	TranslatedTitle   string    `json:"translated_title"`
+	TranslatedSummary string    `json:"translated_summary"`
+	DuplicateOf       string    `json:"duplicate_of,omitempty"`
+	Reported          bool      `json:"reported"`
+	Rating            int       `json:"rating"`
+	Read              bool      `json:"read"`
+	Starred           bool      `json:"starred"`
+}
+
+// APIArticleList is a page of articles. NextCursor is empty on the last page.
+type APIArticleList struct {
+	Articles   []APIArticle `json:"articles"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// APIArticleUpdate is the body of a request to update an article. Fields left out are not
+// changed.
+type APIArticleUpdate struct {
+	Rating  *int  `json:"rating"`
+	Read    *bool `json:"read"`
+	Starred *bool `json:"starred"`
+}
+
+// APIGUIDs is the body of a request for an action on several articles.
+type APIGUIDs struct {
+	GUIDs []string `json:"guids"`
+}
+
+// APIFeed is a feed as returned by the API. LastFetched is null if the feed has not been
+// fetched yet.
+type APIFeed struct {
+	URL         string     `json:"url"`
+	Title       string     `json:"title"`
+	LastFetched *time.Time `json:"last_fetched"`
+	Configured  bool       `json:"configured"`
+	Subscribed  bool       `json:"subscribed"`
+	Articles    int        `json:"articles"`
+}
+
+// APIFeedList is the list of feeds.
+type APIFeedList struct {
+	Feeds []APIFeed `json:"feeds"`
+}
+
+// APINewFeed is the body of a request to subscribe to a feed.
+type APINewFeed struct {
+	URL string `json:"url"`
+}
+
+// registerAPI adds the /api/v1 endpoints to the mux.
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/articles", s.apiListArticles)
+	mux.HandleFunc("GET /api/v1/articles/{guid}", s.apiGetArticle)
+	mux.HandleFunc("PATCH /api/v1/articles/{guid}", s.apiUpdateArticle)
+	mux.HandleFunc("POST /api/v1/articles/rescore", s.apiArticleAction(s.db.ClearScores))
+	mux.HandleFunc("POST /api/v1/articles/reset-reported", s.apiArticleAction(s.db.ResetReportedArticles))
+	mux.HandleFunc("GET /api/v1/feeds", s.apiListFeeds)
+	mux.HandleFunc("POST /api/v1/feeds", s.apiAddFeed)
+	mux.HandleFunc("DELETE /api/v1/feeds/{url}", s.apiDeleteFeed)
+	mux.HandleFunc("POST /api/v1/run/{stage}", s.apiRun)
+	mux.HandleFunc("GET /api/v1/openapi.json", s.apiOpenAPI)
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "no such endpoint: %s %s", r.Method, r.URL.Path)
+	})
+}
+
+// writeJSON writes the value as the JSON response body, with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// writeAPIError writes an error response in the API's error format.
+func writeAPIError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, APIError{Error: APIErrorDetail{Status: status, Message: fmt.Sprintf(format, args...)}})
+}
+
+// readJSON decodes the request body into v, writing an error response and returning false if
+// it is not valid.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return false
+	}
+	return true
+}
+
+// queryBool parses the boolean query parameter, which is nil if it is missing.
+func queryBool(r *http.Request, name string) (*bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false, not %q", name, v)
+	}
+	return &b, nil
+}
+
+// toAPIArticle converts a stored article.
+func toAPIArticle(art storage.Article) APIArticle {
+	aa := APIArticle{
+		GUID:              art.GUID,
+		Title:             art.Title,
+		Link:              art.Link,
+		Description:       art.Description,
+		Content:           art.Content,
+		PublishedDate:     art.PublishedDate,
+		FeedURL:           art.FeedURL,
+		Analysis:          art.Analysis,
+		Model:             art.Model,
+		Tags:              art.Tags,
+		Language:          art.Language,
+		TranslatedTitle:   art.TranslatedTitle,
+		TranslatedSummary: art.TranslatedSummary,
+		Reported:          art.Reported,
+		Rating:            art.Rating,
+		Read:              art.Read,
+		Starred:           art.Starred,
+	}
+	if aa.Tags == nil {
+		aa.Tags = []string{}
+	}
+	if score, err := strconv.Atoi(art.Score); err == nil {
+		aa.Score = &score
+	}
+	if art.IsDuplicate() {
+		aa.DuplicateOf = art.ClusterID
+	}
+	return aa
+}
+
+// apiListArticles returns a page of articles, selected by the q, tag, feed, reported, read
+// and starred query parameters.
+func (s *Server) apiListArticles(w http.ResponseWriter, r *http.Request) {
+	q, err := articleQuery(r, DEFAULT_API_LIMIT)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	articles, next, err := s.db.QueryArticles(q)
+	if errors.Is(err, storage.ErrBadCursor) {
+		writeAPIError(w, http.StatusBadRequest, "invalid cursor")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "error fetching articles: %v", err)
+		return
+	}
+
+	list := APIArticleList{Articles: []APIArticle{}, NextCursor: next}
+	for _, art := range articles {
+		list.Articles = append(list.Articles, toAPIArticle(art))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// apiArticle writes the article with the given GUID.
+func (s *Server) apiArticle(w http.ResponseWriter, guid string) {
+	art, err := s.db.GetArticle(guid)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "error fetching article: %v", err)
+		return
+	}
+	if art == nil {
+		writeAPIError(w, http.StatusNotFound, "no article with guid %q", guid)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIArticle(*art))
+}
+
+// apiGetArticle returns one article. GUIDs are often URLs, so the GUID in the path must be
+// escaped.
+func (s *Server) apiGetArticle(w http.ResponseWriter, r *http.Request) {
+	s.apiArticle(w, r.PathValue("guid"))
+}
+
+// apiUpdateArticle updates an article's rating, read or starred state, and returns it.
+func (s *Server) apiUpdateArticle(w http.ResponseWriter, r *http.Request) {
+	guid := r.PathValue("guid")
+
+	var update APIArticleUpdate
+	if !readJSON(w, r, &update) {
+		return
+	}
+	if update.Rating != nil && (*update.Rating < 0 || *update.Rating > MAX_RATING) {
+		writeAPIError(w, http.StatusBadRequest, "rating must be between 0 and %d", MAX_RATING)
+		return
+	}
+
+	found, err := s.db.UpdateArticleState(guid, storage.ArticleState{Rating: update.Rating, Read: update.Read, Starred: update.Starred})
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "error updating article: %v", err)
+		return
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "no article with guid %q", guid)
+		return
+	}
+	s.apiArticle(w, guid)
+}
+
+// apiArticleAction returns a handler applying the action to the articles listed in the
+// request body.
+func (s *Server) apiArticleAction(action func(guids []string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body APIGUIDs
+		if !readJSON(w, r, &body) {
+			return
+		}
+		if len(body.GUIDs) == 0 {
+			writeAPIError(w, http.StatusBadRequest, "guids must list at least one article")
+			return
+		}
+		if err := action(body.GUIDs); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, "error performing action: %v", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// apiRun queues a stage to run, like the buttons on the schedule page.
+func (s *Server) apiRun(w http.ResponseWriter, r *http.Request) {
+	if s.Runner == nil {
+		writeAPIError(w, http.StatusServiceUnavailable, "stages can only be run from the web server started by run --serve")
+		return
+	}
+
+	err := s.Runner.Trigger(r.PathValue("stage"))
+	switch {
+	case errors.Is(err, schedule.ErrUnknownJob):
+		writeAPIError(w, http.StatusNotFound, "%v", err)
+	case errors.Is(err, schedule.ErrAlreadyQueued):
+		writeAPIError(w, http.StatusConflict, "%v", err)
+	case err != nil:
+		writeAPIError(w, http.StatusInternalServerError, "error queueing run: %v", err)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// toAPIFeed converts a stored feed.
+func (s *Server) toAPIFeed(feed storage.Feed) APIFeed {
+	af := APIFeed{
+		URL:        feed.URL,
+		Title:      feed.Title,
+		Configured: feed.URL == s.FeedURL,
+		Subscribed: feed.Subscribed,
+		Articles:   feed.Articles,
+	}
+	if !feed.LastFetched.IsZero() {
+		af.LastFetched = &feed.LastFetched
+	}
+	return af
+}
+
+// apiListFeeds returns every feed that has been fetched or subscribed to.
+func (s *Server) apiListFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := s.db.ListFeeds()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "error fetching feeds: %v", err)
+		return
+	}
+
+	list := APIFeedList{Feeds: []APIFeed{}}
+	for _, feed := range feeds {
+		list.Feeds = append(list.Feeds, s.toAPIFeed(feed))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// apiAddFeed subscribes to a feed, which is then fetched along with the configured feed.
+func (s *Server) apiAddFeed(w http.ResponseWriter, r *http.Request) {
+	var body APINewFeed
+	if !readJSON(w, r, &body) {
+		return
+	}
+	u, err := url.Parse(body.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeAPIError(w, http.StatusBadRequest, "url must be an http or https URL")
+		return
+	}
+
+	if _, err := s.db.SetFeedSubscribed(body.URL, true); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "error adding feed: %v", err)
+		return
+	}
+	feed, err := s.db.GetFeed(body.URL)
+	if err != nil || feed == nil {
+		writeAPIError(w, http.StatusInternalServerError, "error fetching feed: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.toAPIFeed(*feed))
+}
+
+// apiDeleteFeed unsubscribes from a feed. The articles already fetched from it are kept. The
+// URL in the path must be escaped.
+func (s *Server) apiDeleteFeed(w http.ResponseWriter, r *http.Request) {
+	feedURL := r.PathValue("url")
+	if feedURL == s.FeedURL {
+		writeAPIError(w, http.StatusConflict, "%s is the configured feed, set by --feed-url", feedURL)
+		return
+	}
+
+	found, err := s.db.SetFeedSubscribed(feedURL, false)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "error removing feed: %v", err)
+		return
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "no feed with url %q", feedURL)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiOpenAPI returns the OpenAPI document describing the API.
+func (s *Server) apiOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write([]byte(openAPIDocument))
+}
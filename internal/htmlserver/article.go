@@ -0,0 +1,327 @@
+package htmlserver
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/language"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+const articleTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>AI RSS Scraper - {{.Article.Title}}</title>
+	<style>
+		body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+		nav { margin-bottom: 1em; }
+		table { border-collapse: collapse; margin-bottom: 1.5em; }
+		th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; vertical-align: top; }
+		th { background-color: #f2f2f2; }
+		pre { white-space: pre-wrap; background: #f8f8f8; padding: 1em; border-radius: 4px; }
+		.original { color: #777; }
+		.tag { display: inline-block; background: #eaf2fb; color: #2c3e50; border-radius: 1em; padding: 0.1em 0.7em; margin: 0 0.3em 0.3em 0; font-size: 0.8em; text-decoration: none; }
+		.actions { margin-bottom: 1.5em; padding: 1em; background: #eee; border-radius: 4px; }
+		.actions form { display: inline-block; margin-right: 1.5em; }
+		.muted { color: #888; }
+		.error { color: #c0392b; }
+	</style>
+</head>
+<body>
+	<nav><a href="/">Articles</a> | <a href="/reports">Reports</a> | <a href="/schedule">Schedule</a> | <a href="/prompt">Prompt</a></nav>
+	{{with .Article}}
+	<h1><a href="{{.Link}}" target="_blank">{{if .TranslatedTitle}}{{.TranslatedTitle}}{{else}}{{.Title}}{{end}}</a></h1>
+	{{if .TranslatedTitle}}<p class="original">{{.Title}}</p>{{end}}
+	{{end}}
+
+	{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+	<div class="actions">
+		{{if .CanScore}}
+		<form method="POST">
+			<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+			<input name="model" list="models" value="{{.Model}}" size="20">
+			<datalist id="models">{{range .Models}}<option value="{{.}}">{{end}}</datalist>
+			<button type="submit" name="action" value="rescore">Rescore</button>
+			<a href="/prompt?guid={{.Article.GUID}}">Try other prompts</a>
+		</form>
+		{{end}}
+		<form method="POST">
+			<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+			<select name="rating">
+				{{range .Ratings}}<option value="{{.}}"{{if eq . $.Article.Rating}} selected{{end}}>{{if .}}{{.}} / {{$.MaxRating}}{{else}}unrated{{end}}</option>{{end}}
+			</select>
+			<button type="submit" name="action" value="rate">Rate</button>
+		</form>
+		<form method="POST">
+			<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+			{{if .Article.Reported}}
+			<button type="submit" name="action" value="reset-reported">Reset Reported Status</button>
+			{{else}}
+			<button type="submit" name="action" value="mark-reported">Mark Reported</button>
+			{{end}}
+		</form>
+		<form method="POST" onsubmit="return confirm('Delete this article? It is fetched again if it is still in its feed.')">
+			<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+			<button type="submit" name="action" value="delete">Delete</button>
+		</form>
+	</div>
+
+	{{with .Article}}
+	<table>
+		<tr><th>Score</th><td>{{if .Score}}{{.Score}}{{else}}not scored{{end}}{{if .Model}} ({{.Model}}){{end}}</td></tr>
+		<tr><th>Rating</th><td>{{if .Rating}}{{.Rating}} / {{$.MaxRating}}{{else}}unrated{{end}}</td></tr>
+		<tr><th>Published</th><td>{{.PublishedDate.Format "2006-01-02 15:04"}}</td></tr>
+		<tr><th>Feed</th><td>{{if $.FeedTitle}}{{$.FeedTitle}} <span class="muted">({{.FeedURL}})</span>{{else}}{{.FeedURL}}{{end}}</td></tr>
+		<tr><th>Link</th><td><a href="{{.Link}}" target="_blank">{{.Link}}</a></td></tr>
+		{{if and .CanonicalURL (ne .CanonicalURL .Link)}}<tr><th>Canonical URL</th><td>{{.CanonicalURL}}</td></tr>{{end}}
+		<tr><th>GUID</th><td>{{.GUID}}</td></tr>
+		{{if .Language}}<tr><th>Language</th><td>{{languageName .Language}}</td></tr>{{end}}
+		{{if .Tags}}<tr><th>Tags</th><td>{{range .Tags}}<a class="tag" href="/?tag={{.}}">{{.}}</a>{{end}}</td></tr>{{end}}
+		<tr><th>Reported</th><td>{{if .Reported}}Yes{{else}}No{{end}}</td></tr>
+		<tr><th>Read</th><td>{{if .Read}}Yes{{else}}No{{end}}</td></tr>
+		<tr><th>Starred</th><td>{{if .Starred}}Yes{{else}}No{{end}}</td></tr>
+		{{if .Fingerprint}}<tr><th>Fingerprint</th><td>{{printf "%016x" .Fingerprint}}</td></tr>{{end}}
+		{{if .IsDuplicate}}<tr><th>Duplicate of</th><td><a href="{{articleURL .ClusterID}}">{{.ClusterID}}</a></td></tr>{{end}}
+	</table>
+
+	{{if .TranslatedSummary}}<h2>Summary</h2><p>{{.TranslatedSummary}}</p>{{end}}
+	{{if .Description}}<h2>Description</h2><p>{{.Description}}</p>{{end}}
+	{{if .Analysis}}<h2>Analysis</h2><pre>{{.Analysis}}</pre>{{end}}
+	{{if .Content}}
+	<h2>Content</h2>
+	<details><summary>Stored content ({{len .Content}} bytes)</summary><pre>{{.Content}}</pre></details>
+	{{end}}
+
+	{{if .Duplicates}}
+	<h2>Also Seen On</h2>
+	<ul>
+		{{range .Duplicates}}<li><a href="{{articleURL .GUID}}">{{.Title}}</a> ({{.FeedURL}})</li>{{end}}
+	</ul>
+	{{end}}
+	{{end}}
+
+	<h2>Prompt</h2>
+	{{with .Scores}}
+	<p class="muted">As sent to {{(index . 0).Model}} on {{(index . 0).ScoredAt.Local.Format "2006-01-02 15:04"}}.</p>
+	<pre>{{(index . 0).Prompt}}</pre>
+	{{else}}
+	<p class="muted">No prompt has been recorded; the article was not scored since scoring history was added.</p>
+	{{end}}
+
+	{{if .Scores}}
+	<h2>Scoring History</h2>
+	<table>
+		<tr><th>Scored</th><th>Model</th><th>Score</th><th>Response</th></tr>
+		{{range .Scores}}
+		<tr>
+			<td>{{.ScoredAt.Local.Format "2006-01-02 15:04"}}</td>
+			<td>{{.Model}}</td>
+			<td>{{.Score}}</td>
+			<td>
+				<details><summary>Response</summary><pre>{{.Analysis}}</pre></details>
+				<details><summary>Prompt</summary><pre>{{.Prompt}}</pre></details>
+			</td>
+		</tr>
+		{{end}}
+	</table>
+	{{end}}
+
+	{{if .Reports}}
+	<h2>Included In</h2>
+	<ul>
+		{{range .Reports}}<li><a href="/reports/{{.ID}}">#{{.ID}} {{.Title}}</a> ({{.CreatedAt.Local.Format "2006-01-02 15:04"}})</li>{{end}}
+	</ul>
+	{{end}}
+</body>
+</html>
+`
+
+// Scorer scores articles on request from the article and prompt pages.
+type Scorer interface {
+	// Model returns the model that articles are scored with, unless another is chosen.
+	Model() string
+	// Prompt returns the prompt template that articles are scored with.
+	Prompt() (string, error)
+	// ScoreArticle scores the article with the given GUID with the model, and stores the
+	// result.
+	ScoreArticle(ctx context.Context, guid, model string) error
+	// TryPrompt scores the article with the given GUID with the prompt template and model,
+	// without storing the result.
+	TryPrompt(ctx context.Context, prompt, guid, model string) (PromptTrial, error)
+}
+
+// ArticleData is the data rendered by the article page.
+type ArticleData struct {
+	Article   *storage.Article
+	FeedTitle string
+	Reports   []storage.Report
+	Scores    []storage.ScoreRecord
+
+	// CanScore is true if the article can be rescored, with Model by default or one of
+	// Models.
+	CanScore bool
+	Model    string
+	Models   []string
+
+	MaxRating int
+	Ratings   []int
+	Error     string
+	CSRFToken string
+}
+
+// articleURL returns the path of the article's page. GUIDs are often URLs themselves, so the
+// GUID is escaped.
+func articleURL(guid string) string {
+	return "/article/" + url.PathEscape(guid)
+}
+
+// handleArticleRedirect redirects the article pages' old URLs, which took the GUID as a query
+// parameter, to their new ones.
+func (s *Server) handleArticleRedirect(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, articleURL(r.URL.Query().Get("guid")), http.StatusMovedPermanently)
+}
+
+func (s *Server) handleArticle(w http.ResponseWriter, r *http.Request) {
+	s.renderArticle(w, r, r.PathValue("guid"), "", http.StatusOK)
+}
+
+// renderArticle writes the article's page with the given status, and the error message, if
+// any, from the action taken on it.
+func (s *Server) renderArticle(w http.ResponseWriter, r *http.Request, guid, message string, status int) {
+	art, err := s.db.GetArticle(guid)
+	if err != nil {
+		http.Error(w, "Error fetching article: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if art == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	reports, err := s.db.GetArticleReports(guid)
+	if err != nil {
+		http.Error(w, "Error fetching reports: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	scores, err := s.db.GetScoreHistory(guid)
+	if err != nil {
+		http.Error(w, "Error fetching scoring history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := ArticleData{
+		Article:   art,
+		Reports:   reports,
+		Scores:    scores,
+		MaxRating: MAX_RATING,
+		Error:     message,
+		CSRFToken: csrfToken(w, r),
+	}
+	for rating := 0; rating <= MAX_RATING; rating++ {
+		data.Ratings = append(data.Ratings, rating)
+	}
+
+	feed, err := s.db.GetFeed(art.FeedURL)
+	if err != nil {
+		http.Error(w, "Error fetching feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if feed != nil {
+		data.FeedTitle = feed.Title
+	}
+
+	if s.Scorer != nil {
+		data.CanScore = true
+		data.Model = s.Scorer.Model()
+		models, err := s.db.ListModels()
+		if err != nil {
+			http.Error(w, "Error fetching models: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data.Model != "" && !slices.Contains(models, data.Model) {
+			models = append([]string{data.Model}, models...)
+		}
+		data.Models = models
+	}
+
+	funcMap := template.FuncMap{"languageName": language.Name, "articleURL": articleURL}
+	tmpl, err := template.New("article").Funcs(funcMap).Parse(articleTemplate)
+	if err != nil {
+		http.Error(w, "Error parsing template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleArticleAction performs one of the actions on the article page: rescore, rate,
+// mark-reported, reset-reported or delete.
+func (s *Server) handleArticleAction(w http.ResponseWriter, r *http.Request) {
+	guid := r.PathValue("guid")
+	exists, err := s.db.ArticleExists(guid)
+	if err != nil {
+		http.Error(w, "Error fetching article: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.FormValue("action") {
+	case "rescore":
+		if s.Scorer == nil {
+			http.Error(w, "Scoring is not available", http.StatusServiceUnavailable)
+			return
+		}
+		model := r.FormValue("model")
+		if model == "" {
+			model = s.Scorer.Model()
+		}
+		if err := s.Scorer.ScoreArticle(r.Context(), guid, model); err != nil {
+			// The page is shown again with the error, as scoring fails for reasons the
+			// reader can often fix, such as a mistyped model.
+			s.renderArticle(w, r, guid, fmt.Sprintf("Error scoring article with %s: %v", model, err), http.StatusBadGateway)
+			return
+		}
+	case "rate":
+		rating, err := strconv.Atoi(r.FormValue("rating"))
+		if err != nil || rating < 0 || rating > MAX_RATING {
+			http.Error(w, fmt.Sprintf("Rating must be between 0 and %d", MAX_RATING), http.StatusBadRequest)
+			return
+		}
+		_, err = s.db.UpdateArticleState(guid, storage.ArticleState{Rating: &rating})
+	case "mark-reported":
+		err = s.db.MarkArticlesReported([]string{guid})
+	case "reset-reported":
+		err = s.db.ResetReportedArticles([]string{guid})
+	case "delete":
+		if _, err := s.db.DeleteArticle(guid); err != nil {
+			http.Error(w, "Error deleting article: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, "Error performing action: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, articleURL(guid), http.StatusSeeOther)
+}
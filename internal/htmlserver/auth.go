@@ -0,0 +1,281 @@
+package htmlserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// How requests to the web interface are authenticated.
+const (
+	AUTH_NONE   = "none"
+	AUTH_BASIC  = "basic"
+	AUTH_HEADER = "header"
+)
+
+// AUTH_MODES lists the supported authentication modes.
+var AUTH_MODES = []string{AUTH_NONE, AUTH_BASIC, AUTH_HEADER}
+
+// DEFAULT_AUTH_HEADER is the header that carries the user name in header mode.
+const DEFAULT_AUTH_HEADER = "X-Forwarded-User"
+
+// DEFAULT_TRUSTED_PROXIES are the proxies trusted in header mode unless others are given:
+// only ones on the same host, such as a sidecar in the same pod.
+var DEFAULT_TRUSTED_PROXIES = []string{"127.0.0.0/8", "::1"}
+
+// CSRF_COOKIE is the cookie holding the CSRF token, and CSRF_FIELD the form field that must
+// repeat it.
+const (
+	CSRF_COOKIE = "csrf_token"
+	CSRF_FIELD  = "csrf_token"
+)
+
+// Auth authenticates the requests to the web interface.
+type Auth struct {
+	mode string
+
+	// users maps user names to bcrypt password hashes, for basic mode.
+	users map[string]string
+
+	// tokens are the bearer tokens accepted for the /api/ endpoints, in any mode but none.
+	tokens []string
+
+	// header carries the name of the user signed in to the proxy in front of us, in header
+	// mode. The header is only believed from the trustedProxies addresses.
+	header         string
+	trustedProxies []*net.IPNet
+
+	// verified caches the credentials that have passed a bcrypt check, by their SHA-256, as
+	// bcrypt is deliberately too slow to run on every request.
+	verified sync.Map
+
+	// dummyHash is checked against for unknown users, so that they take as long to reject
+	// as a wrong password.
+	dummyHash []byte
+}
+
+// NewAuth returns an authenticator for the mode. Users are given as "name:bcrypt-hash", and
+// trusted proxies as IP addresses or CIDR ranges, defaulting to DEFAULT_TRUSTED_PROXIES. An
+// empty mode means none.
+func NewAuth(mode string, users, tokens []string, header string, trustedProxies []string) (*Auth, error) {
+	if mode == "" {
+		mode = AUTH_NONE
+	}
+	if !slices.Contains(AUTH_MODES, mode) {
+		return nil, fmt.Errorf("unknown auth mode %q; must be one of %s", mode, strings.Join(AUTH_MODES, ", "))
+	}
+	if header == "" {
+		header = DEFAULT_AUTH_HEADER
+	}
+
+	a := &Auth{mode: mode, users: make(map[string]string), tokens: tokens, header: header}
+	for _, user := range users {
+		name, hash, ok := strings.Cut(user, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("auth user %q must be name:bcrypt-hash", user)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("auth user %s: password is not a bcrypt hash: %v", name, err)
+		}
+		a.users[name] = hash
+	}
+	if len(trustedProxies) == 0 {
+		trustedProxies = DEFAULT_TRUSTED_PROXIES
+	}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %v", err)
+		}
+		a.trustedProxies = append(a.trustedProxies, ipNet)
+	}
+
+	if mode == AUTH_BASIC && len(a.users) == 0 {
+		return nil, fmt.Errorf("auth mode basic needs at least one user")
+	}
+
+	var err error
+	a.dummyHash, err = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Mode returns the authentication mode.
+func (a *Auth) Mode() string {
+	return a.mode
+}
+
+type contextKey string
+
+// userKey is the context key holding the name of the authenticated user.
+const userKey contextKey = "user"
+
+// User returns the name of the user that made the request, or "" if requests are not
+// authenticated. Requests authenticated by an API token are made by "token".
+func User(r *http.Request) string {
+	user, _ := r.Context().Value(userKey).(string)
+	return user
+}
+
+// isAPI returns true if the request is for the JSON API.
+func isAPI(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// authenticate returns the name of the user that made the request, and false if the request
+// is not authenticated.
+func (a *Auth) authenticate(r *http.Request) (string, bool) {
+	if a == nil || a.mode == AUTH_NONE {
+		return "", true
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return "token", isAPI(r) && a.validToken(token)
+	}
+
+	switch a.mode {
+	case AUTH_BASIC:
+		user, password, ok := r.BasicAuth()
+		if !ok {
+			return "", false
+		}
+		return user, a.validPassword(user, password)
+	case AUTH_HEADER:
+		if !a.fromTrustedProxy(r) {
+			return "", false
+		}
+		user := r.Header.Get(a.header)
+		return user, user != ""
+	}
+	return "", false
+}
+
+// validToken returns true if the token is one of the API tokens.
+func (a *Auth) validToken(token string) bool {
+	valid := false
+	for _, t := range a.tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// validPassword returns true if the password matches the user's bcrypt hash.
+func (a *Auth) validPassword(user, password string) bool {
+	hash, ok := a.users[user]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+		return false
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+	if _, ok := a.verified.Load(key); ok {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	a.verified.Store(key, struct{}{})
+	return true
+}
+
+// fromTrustedProxy returns true if the request came from one of the trusted proxies.
+func (a *Auth) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	for _, ipNet := range a.trustedProxies {
+		if ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// unauthorized writes the response to a request that is not authenticated.
+func (a *Auth) unauthorized(w http.ResponseWriter, r *http.Request) {
+	if a.mode == AUTH_BASIC {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ai-rss-scraper", charset="UTF-8"`)
+	}
+	if isAPI(r) {
+		writeAPIError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+	http.Error(w, "Authentication required", http.StatusUnauthorized)
+}
+
+// requireAuth returns a handler that only passes authenticated requests on to next.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := s.Auth.authenticate(r)
+		if !ok {
+			s.Auth.unauthorized(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+	})
+}
+
+// csrfToken returns the CSRF token to include in the forms on the page, from the request's
+// cookie, setting a new cookie if it has none.
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(CSRF_COOKIE); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRF_COOKIE,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(siteURL(r), "https:"),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// requireCSRF returns a handler that rejects form submissions that do not carry the CSRF
+// token set in the cookie by the page with the form. The JSON API does not use forms, and is
+// protected against cross-site requests by checking their origin; scripts should use it.
+func requireCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if safe || isAPI(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(CSRF_COOKIE)
+		if err != nil || cookie.Value == "" ||
+			subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostFormValue(CSRF_FIELD))) != 1 {
+			http.Error(w, "Invalid or missing CSRF token; reload the page and try again", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
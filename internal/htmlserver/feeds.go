@@ -0,0 +1,87 @@
+package htmlserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// Defaults for the articles published in the feeds, when the request does not say.
+const (
+	DEFAULT_FEED_THRESHOLD = 50
+	DEFAULT_FEED_AGE_DAYS  = 7
+)
+
+// feedContentTypes maps each feed format to the Content-Type it is served with.
+var feedContentTypes = map[string]string{
+	report.FORMAT_ATOM:     "application/atom+xml; charset=utf-8",
+	report.FORMAT_RSS:      "application/rss+xml; charset=utf-8",
+	report.FORMAT_JSONFEED: "application/feed+json; charset=utf-8",
+}
+
+// siteURL returns the base URL that the request was made to, honoring X-Forwarded-Proto
+// from a reverse proxy.
+func siteURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// queryInt returns the integer query parameter, or def if it is missing or not a number.
+func queryInt(r *http.Request, name string, def int) int {
+	if v, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil {
+		return v
+	}
+	return def
+}
+
+// feedHandler returns a handler publishing the top-scored articles in the given feed
+// format. The threshold, age (in days) and tag query parameters select the articles.
+func (s *Server) feedHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		threshold := queryInt(r, "threshold", s.FeedThreshold)
+		ageDays := queryInt(r, "age", s.FeedAgeDays)
+		tag := storage.NormalizeTag(r.URL.Query().Get("tag"))
+
+		since := time.Now().Add(time.Duration(-ageDays) * 24 * time.Hour)
+		articles, err := s.db.GetArticlesAfter(since)
+		if err != nil {
+			http.Error(w, "Error fetching articles: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		feedNames, err := s.db.GetFeedNames()
+		if err != nil {
+			http.Error(w, "Error fetching feed names: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		title := fmt.Sprintf("AI RSS Scraper (score >= %d)", threshold)
+		if tag != "" {
+			title = fmt.Sprintf("AI RSS Scraper: %s (score >= %d)", tag, threshold)
+		}
+		rep := report.NewReport(title, report.Select(articles, threshold, tag))
+		rep.Threshold = threshold
+		rep.AgeDays = ageDays
+		rep.FeedNames = feedNames
+		rep.SiteURL = siteURL(r)
+
+		content, err := rep.Generate(format)
+		if err != nil {
+			http.Error(w, "Error generating feed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", feedContentTypes[format])
+		_, _ = w.Write([]byte(content))
+	}
+}
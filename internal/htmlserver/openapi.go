@@ -0,0 +1,247 @@
+package htmlserver
+
+// openAPIDocument describes the /api/v1 endpoints. It must be kept in step with api.go.
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "AI RSS Scraper API",
+    "version": "1",
+    "description": "Articles fetched and scored by ai-rss-scraper, and the feeds they come from. GUIDs and feed URLs in paths must be URL-escaped."
+  },
+  "servers": [{"url": "/api/v1"}],
+  "paths": {
+    "/articles": {
+      "get": {
+        "summary": "List articles, most recently published first",
+        "operationId": "listArticles",
+        "parameters": [
+          {"name": "q", "in": "query", "description": "Words to search for in the title, description, content and analysis; all must match", "schema": {"type": "string"}},
+          {"name": "tag", "in": "query", "description": "Only articles with this topic tag", "schema": {"type": "string"}},
+          {"name": "feed", "in": "query", "description": "Only articles from the feed with this URL", "schema": {"type": "string"}},
+          {"name": "model", "in": "query", "description": "Only articles scored by this model", "schema": {"type": "string"}},
+          {"name": "min_score", "in": "query", "description": "Only scored articles with at least this score", "schema": {"type": "integer"}},
+          {"name": "max_score", "in": "query", "description": "Only scored articles with at most this score", "schema": {"type": "integer"}},
+          {"name": "after", "in": "query", "description": "Only articles published on or after this day", "schema": {"type": "string", "format": "date"}},
+          {"name": "before", "in": "query", "description": "Only articles published on or before this day", "schema": {"type": "string", "format": "date"}},
+          {"name": "reported", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "scored", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "read", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "starred", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "sort", "in": "query", "schema": {"type": "string", "enum": ["date", "score", "title", "feed"], "default": "date"}},
+          {"name": "order", "in": "query", "schema": {"type": "string", "enum": ["asc", "desc"], "default": "desc"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 500, "default": 50}},
+          {"name": "cursor", "in": "query", "description": "The next_cursor of the previous page", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "A page of articles", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ArticleList"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/articles/{guid}": {
+      "parameters": [
+        {"name": "guid", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Get an article",
+        "operationId": "getArticle",
+        "responses": {
+          "200": {"description": "The article", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Article"}}}},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Update an article's rating, read or starred state",
+        "operationId": "updateArticle",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ArticleUpdate"}}}},
+        "responses": {
+          "200": {"description": "The updated article", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Article"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/articles/rescore": {
+      "post": {
+        "summary": "Clear the scores of articles, so that they are scored again",
+        "operationId": "rescoreArticles",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GUIDs"}}}},
+        "responses": {
+          "204": {"description": "The scores were cleared"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/articles/reset-reported": {
+      "post": {
+        "summary": "Mark articles as not reported, so that they can appear in another report",
+        "operationId": "resetReportedArticles",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GUIDs"}}}},
+        "responses": {
+          "204": {"description": "The articles were reset"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/feeds": {
+      "get": {
+        "summary": "List the feeds that have been fetched or subscribed to",
+        "operationId": "listFeeds",
+        "responses": {
+          "200": {"description": "The feeds", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/FeedList"}}}},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Subscribe to a feed, to be fetched along with the configured feed",
+        "operationId": "addFeed",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewFeed"}}}},
+        "responses": {
+          "201": {"description": "The feed", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Feed"}}}},
+          "400": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/feeds/{url}": {
+      "parameters": [
+        {"name": "url", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "delete": {
+        "summary": "Unsubscribe from a feed, keeping the articles already fetched",
+        "operationId": "deleteFeed",
+        "responses": {
+          "204": {"description": "The feed was unsubscribed"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/run/{stage}": {
+      "parameters": [
+        {"name": "stage", "in": "path", "required": true, "schema": {"type": "string", "enum": ["fetch", "score", "report", "prune"]}}
+      ],
+      "post": {
+        "summary": "Queue a stage to run after the one in progress, under run --serve",
+        "operationId": "runStage",
+        "responses": {
+          "202": {"description": "The stage was queued"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"},
+          "500": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "operationId": "getOpenAPI",
+        "responses": {
+          "200": {"description": "The OpenAPI document", "content": {"application/json": {}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "responses": {
+      "Error": {"description": "An error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {
+          "error": {
+            "type": "object",
+            "required": ["status", "message"],
+            "properties": {
+              "status": {"type": "integer", "description": "The HTTP status code"},
+              "message": {"type": "string"}
+            }
+          }
+        }
+      },
+      "Article": {
+        "type": "object",
+        "properties": {
+          "guid": {"type": "string"},
+          "title": {"type": "string"},
+          "link": {"type": "string"},
+          "description": {"type": "string"},
+          "content": {"type": "string"},
+          "published_date": {"type": "string", "format": "date-time"},
+          "feed_url": {"type": "string"},
+          "score": {"type": "integer", "nullable": true, "description": "Null if the article has not been scored"},
+          "analysis": {"type": "string"},
+          "model": {"type": "string"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "language": {"type": "string"},
+          "translated_title": {"type": "string"},
+          "translated_summary": {"type": "string"},
+          "duplicate_of": {"type": "string", "description": "The GUID of the article this one duplicates, if any"},
+          "reported": {"type": "boolean"},
+          "rating": {"type": "integer", "minimum": 0, "maximum": 5, "description": "0 if unrated"},
+          "read": {"type": "boolean"},
+          "starred": {"type": "boolean"}
+        }
+      },
+      "ArticleList": {
+        "type": "object",
+        "properties": {
+          "articles": {"type": "array", "items": {"$ref": "#/components/schemas/Article"}},
+          "next_cursor": {"type": "string", "description": "Empty on the last page"}
+        }
+      },
+      "ArticleUpdate": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "rating": {"type": "integer", "minimum": 0, "maximum": 5},
+          "read": {"type": "boolean"},
+          "starred": {"type": "boolean"}
+        }
+      },
+      "GUIDs": {
+        "type": "object",
+        "required": ["guids"],
+        "additionalProperties": false,
+        "properties": {
+          "guids": {"type": "array", "minItems": 1, "items": {"type": "string"}}
+        }
+      },
+      "Feed": {
+        "type": "object",
+        "properties": {
+          "url": {"type": "string"},
+          "title": {"type": "string"},
+          "last_fetched": {"type": "string", "format": "date-time", "nullable": true},
+          "configured": {"type": "boolean", "description": "True for the feed set by --feed-url"},
+          "subscribed": {"type": "boolean", "description": "True for feeds added through the API"},
+          "articles": {"type": "integer"}
+        }
+      },
+      "FeedList": {
+        "type": "object",
+        "properties": {
+          "feeds": {"type": "array", "items": {"$ref": "#/components/schemas/Feed"}}
+        }
+      },
+      "NewFeed": {
+        "type": "object",
+        "required": ["url"],
+        "additionalProperties": false,
+        "properties": {
+          "url": {"type": "string", "format": "uri"}
+        }
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,435 @@
+package htmlserver
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// MAX_PLAYGROUND_ARTICLES is the most articles that can be scored at once on the prompt page.
+// Each is scored twice, with the current and the edited prompt, while the page waits.
+const MAX_PLAYGROUND_ARTICLES = 5
+
+// PLAYGROUND_CANDIDATES is the number of articles offered for scoring on the prompt page.
+const PLAYGROUND_CANDIDATES = 20
+
+const promptTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>AI RSS Scraper - Prompt</title>
+	<style>
+		body { font-family: sans-serif; margin: 2em; }
+		nav { margin-bottom: 1em; }
+		table { width: 100%; border-collapse: collapse; margin-bottom: 1.5em; }
+		th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; vertical-align: top; }
+		th { background-color: #f2f2f2; }
+		textarea { width: 100%; font-family: monospace; }
+		pre { white-space: pre-wrap; background: #f8f8f8; padding: 0.5em; border-radius: 4px; margin: 0.3em 0; }
+		.toolbar { margin: 0.5em 0 1.5em 0; }
+		.toolbar button { margin-right: 0.5em; }
+		.picker { max-height: 20em; overflow-y: auto; border: 1px solid #ddd; padding: 0.5em; margin-bottom: 0.5em; }
+		.picker label { display: block; }
+		.muted { color: #888; }
+		.message { color: #27ae60; }
+		.error { color: #c0392b; }
+		.higher { color: green; font-weight: bold; }
+		.lower { color: #c0392b; font-weight: bold; }
+		.compare { width: 50%; }
+	</style>
+</head>
+<body>
+	<nav><a href="/">Articles</a> | <a href="/reports">Reports</a> | <a href="/schedule">Schedule</a> | <b>Prompt</b></nav>
+	<h1>Prompt</h1>
+	<p class="muted">
+		Articles are scored with {{if .Active}}prompt version {{.Active.ID}}, saved {{.Active.CreatedAt.Local.Format "2006-01-02 15:04"}}{{else}}the configured prompt{{end}}.
+		The prompt is a template: {{"{{.Title}}"}}, {{"{{.Description}}"}} and {{"{{.Content}}"}} are replaced by the article's.
+		The request for topic tags is added to the end of every prompt.
+	</p>
+	{{if .Message}}<p class="message">{{.Message}}</p>{{end}}
+	{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+
+	<form method="POST" action="/prompt">
+		<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+		<textarea name="template" rows="14">{{.Template}}</textarea>
+
+		<h2>Try It</h2>
+		<p>
+			<label>Model <input name="model" list="models" value="{{.Model}}" size="20"></label>
+			<datalist id="models">{{range .Models}}<option value="{{.}}">{{end}}</datalist>
+		</p>
+		<p class="muted">Pick up to {{.MaxArticles}} articles to score with both the current prompt and the one above. Nothing is stored.</p>
+		<p>
+			<input type="search" name="q" value="{{.Search}}" placeholder="Search articles">
+			<button type="submit" name="action" value="find">Find</button>
+		</p>
+		<div class="picker">
+			{{range .Candidates}}
+			<label><input type="checkbox" name="guids" value="{{.GUID}}"{{if index $.Selected .GUID}} checked{{end}}>
+				[{{if .Score}}{{.Score}}{{else}}-{{end}}] {{if .TranslatedTitle}}{{.TranslatedTitle}}{{else}}{{.Title}}{{end}}
+				<span class="muted">({{.PublishedDate.Format "2006-01-02"}})</span></label>
+			{{else}}
+			<span class="muted">No articles found.</span>
+			{{end}}
+		</div>
+		<div class="toolbar">
+			{{if .CanRun}}<button type="submit" name="action" value="run">Run</button>{{end}}
+			<button type="submit" name="action" value="save" onclick="return confirm('Score all articles from now on with this prompt?')">Save as Active Prompt</button>
+			{{if .Active}}<button type="submit" name="action" value="revert" onclick="return confirm('Score articles with the configured prompt again?')">Use Configured Prompt</button>{{end}}
+		</div>
+	</form>
+
+	{{if .Results}}
+	<h2>Results</h2>
+	<table>
+		<tr><th>Article</th><th>Stored</th><th class="compare">Current prompt</th><th class="compare">Edited prompt</th></tr>
+		{{range .Results}}
+		<tr>
+			<td><a href="{{articleURL .Article.GUID}}">{{if .Article.TranslatedTitle}}{{.Article.TranslatedTitle}}{{else}}{{.Article.Title}}{{end}}</a></td>
+			<td>{{if .Article.Score}}{{.Article.Score}}{{else}}-{{end}}</td>
+			<td>
+				{{if .CurrentError}}<span class="error">{{.CurrentError}}</span>{{else}}
+				<b>{{.Current.Score}}</b>{{if .Current.Tags}} <span class="muted">{{join .Current.Tags ", "}}</span>{{end}}
+				<pre>{{.Current.Response}}</pre>
+				<details><summary>Prompt</summary><pre>{{.Current.Prompt}}</pre></details>
+				{{end}}
+			</td>
+			<td>
+				{{if .CandidateError}}<span class="error">{{.CandidateError}}</span>{{else}}
+				<b class="{{.Change}}">{{.Candidate.Score}}</b>{{if .Candidate.Tags}} <span class="muted">{{join .Candidate.Tags ", "}}</span>{{end}}
+				<pre>{{.Candidate.Response}}</pre>
+				<details><summary>Prompt</summary><pre>{{.Candidate.Prompt}}</pre></details>
+				{{end}}
+			</td>
+		</tr>
+		{{end}}
+	</table>
+	{{end}}
+
+	{{if .Versions}}
+	<h2>Saved Versions</h2>
+	<table>
+		<tr><th>Version</th><th>Saved</th><th>Prompt</th></tr>
+		{{range .Versions}}
+		<tr>
+			<td>{{.ID}}{{if .Active}} <b>(active)</b>{{end}}</td>
+			<td>{{.CreatedAt.Local.Format "2006-01-02 15:04"}}</td>
+			<td><details><summary><a href="/prompt?version={{.ID}}">Edit a copy</a></summary><pre>{{.Template}}</pre></details></td>
+		</tr>
+		{{end}}
+	</table>
+	{{end}}
+</body>
+</html>
+`
+
+// PromptTrial is the result of scoring an article with a prompt, without storing it.
+type PromptTrial struct {
+	// Prompt is the prompt as sent to the model, and Response its reply.
+	Prompt   string
+	Response string
+	Score    string
+	Tags     []string
+}
+
+// PromptComparison is the result of scoring an article with the current and edited prompts.
+type PromptComparison struct {
+	Article        storage.Article
+	Current        PromptTrial
+	Candidate      PromptTrial
+	CurrentError   string
+	CandidateError string
+}
+
+// Change returns "higher" or "lower" if the edited prompt scored the article higher or lower
+// than the current one.
+func (c PromptComparison) Change() string {
+	current, err1 := strconv.Atoi(c.Current.Score)
+	candidate, err2 := strconv.Atoi(c.Candidate.Score)
+	switch {
+	case err1 != nil || err2 != nil || c.CurrentError != "" || c.CandidateError != "":
+		return ""
+	case candidate > current:
+		return "higher"
+	case candidate < current:
+		return "lower"
+	}
+	return ""
+}
+
+// PromptData is the data rendered by the prompt page.
+type PromptData struct {
+	// Template is the prompt in the editor.
+	Template string
+	Active   *storage.PromptVersion
+	Versions []storage.PromptVersion
+
+	// CanRun is true if articles can be scored, with Model by default or one of Models.
+	CanRun bool
+	Model  string
+	Models []string
+
+	// Candidates are the articles that can be picked to score, matching Search, and Selected
+	// the GUIDs of the ones picked.
+	Search      string
+	Candidates  []storage.Article
+	Selected    map[string]bool
+	MaxArticles int
+
+	Results   []PromptComparison
+	Message   string
+	Error     string
+	CSRFToken string
+}
+
+// handlePrompt shows the prompt page, with the current prompt in the editor, or a copy of the
+// saved version given by the version query parameter. Articles given by guid query
+// parameters are picked for scoring.
+func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	data := PromptData{Selected: make(map[string]bool)}
+	for _, guid := range r.URL.Query()["guid"] {
+		data.Selected[guid] = true
+	}
+	if id := r.URL.Query().Get("saved"); id != "" {
+		data.Message = "Saved as version " + id + ". Articles are now scored with it."
+	}
+
+	if v := r.URL.Query().Get("version"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		version, err := s.db.GetPrompt(id)
+		if err != nil {
+			http.Error(w, "Error fetching prompt: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if version == nil {
+			http.NotFound(w, r)
+			return
+		}
+		data.Template = version.Template
+	} else {
+		current, err := s.currentPrompt()
+		if err != nil {
+			http.Error(w, "Error fetching prompt: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data.Template = current
+	}
+
+	s.renderPrompt(w, r, data, http.StatusOK)
+}
+
+// handlePromptAction finds articles to score, scores them with the current and edited
+// prompts, saves the edited prompt as the active one, or goes back to the configured prompt.
+func (s *Server) handlePromptAction(w http.ResponseWriter, r *http.Request) {
+	data := PromptData{
+		Template: strings.ReplaceAll(r.FormValue("template"), "\r\n", "\n"),
+		Model:    strings.TrimSpace(r.FormValue("model")),
+		Search:   strings.TrimSpace(r.FormValue("q")),
+		Selected: make(map[string]bool),
+	}
+	guids := r.Form["guids"]
+	for _, guid := range guids {
+		data.Selected[guid] = true
+	}
+
+	switch r.FormValue("action") {
+	case "find":
+	case "run":
+		if s.Scorer == nil {
+			http.Error(w, "Scoring is not available", http.StatusServiceUnavailable)
+			return
+		}
+		if len(guids) == 0 || len(guids) > MAX_PLAYGROUND_ARTICLES {
+			data.Error = fmt.Sprintf("Pick between 1 and %d articles to score.", MAX_PLAYGROUND_ARTICLES)
+			s.renderPrompt(w, r, data, http.StatusBadRequest)
+			return
+		}
+		if err := checkPrompt(data.Template); err != nil {
+			data.Error = err.Error()
+			s.renderPrompt(w, r, data, http.StatusBadRequest)
+			return
+		}
+		current, err := s.currentPrompt()
+		if err != nil {
+			http.Error(w, "Error fetching prompt: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data.Model == "" {
+			data.Model = s.Scorer.Model()
+		}
+		data.Results, err = s.comparePrompts(r, current, data.Template, data.Model, guids)
+		if err != nil {
+			http.Error(w, "Error fetching articles: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "save":
+		if err := checkPrompt(data.Template); err != nil {
+			data.Error = err.Error()
+			s.renderPrompt(w, r, data, http.StatusBadRequest)
+			return
+		}
+		version, err := s.db.SavePrompt(data.Template)
+		if err != nil {
+			http.Error(w, "Error saving prompt: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/prompt?saved=%d", version.ID), http.StatusSeeOther)
+		return
+	case "revert":
+		if err := s.db.DeactivatePrompts(); err != nil {
+			http.Error(w, "Error saving prompt: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/prompt", http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+
+	s.renderPrompt(w, r, data, http.StatusOK)
+}
+
+// currentPrompt returns the prompt template that articles are scored with.
+func (s *Server) currentPrompt() (string, error) {
+	if s.Scorer != nil {
+		return s.Scorer.Prompt()
+	}
+	active, err := s.db.GetActivePrompt()
+	if err != nil || active == nil {
+		return "", err
+	}
+	return active.Template, nil
+}
+
+// checkPrompt returns an error if the prompt template is empty or cannot be parsed.
+func checkPrompt(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("the prompt must not be empty")
+	}
+	if _, err := texttemplate.New("prompt").Parse(text); err != nil {
+		return fmt.Errorf("the prompt is not a valid template: %v", err)
+	}
+	return nil
+}
+
+// comparePrompts scores the articles with both prompt templates, all at once.
+func (s *Server) comparePrompts(r *http.Request, current, candidate, model string, guids []string) ([]PromptComparison, error) {
+	results := make([]PromptComparison, 0, len(guids))
+	for _, guid := range guids {
+		art, err := s.db.GetArticle(guid)
+		if err != nil {
+			return nil, err
+		}
+		if art != nil {
+			results = append(results, PromptComparison{Article: *art})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		trials := []struct {
+			template string
+			trial    *PromptTrial
+			message  *string
+		}{
+			{current, &results[i].Current, &results[i].CurrentError},
+			{candidate, &results[i].Candidate, &results[i].CandidateError},
+		}
+		for _, t := range trials {
+			wg.Go(func() {
+				trial, err := s.Scorer.TryPrompt(r.Context(), t.template, results[i].Article.GUID, model)
+				if err != nil {
+					*t.message = err.Error()
+					return
+				}
+				*t.trial = trial
+			})
+		}
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// renderPrompt fills in the rest of the prompt page's data, and writes it with the given
+// status.
+func (s *Server) renderPrompt(w http.ResponseWriter, r *http.Request, data PromptData, status int) {
+	var err error
+	data.MaxArticles = MAX_PLAYGROUND_ARTICLES
+	data.CSRFToken = csrfToken(w, r)
+
+	if data.Active, err = s.db.GetActivePrompt(); err != nil {
+		http.Error(w, "Error fetching prompt: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if data.Versions, err = s.db.ListPrompts(); err != nil {
+		http.Error(w, "Error fetching prompts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if s.Scorer != nil {
+		data.CanRun = true
+		if data.Model == "" {
+			data.Model = s.Scorer.Model()
+		}
+		if data.Models, err = s.db.ListModels(); err != nil {
+			http.Error(w, "Error fetching models: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data.Model != "" && !slices.Contains(data.Models, data.Model) {
+			data.Models = append([]string{data.Model}, data.Models...)
+		}
+	}
+
+	// The articles already picked stay on offer, ahead of those matching the search.
+	offered := make(map[string]bool)
+	for guid := range data.Selected {
+		art, err := s.db.GetArticle(guid)
+		if err != nil {
+			http.Error(w, "Error fetching article: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if art != nil {
+			data.Candidates = append(data.Candidates, *art)
+			offered[guid] = true
+		}
+	}
+	slices.SortFunc(data.Candidates, func(a, b storage.Article) int {
+		return b.PublishedDate.Compare(a.PublishedDate)
+	})
+	found, _, err := s.db.QueryArticles(storage.ArticleQuery{Search: data.Search, Limit: PLAYGROUND_CANDIDATES})
+	if err != nil {
+		http.Error(w, "Error fetching articles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, art := range found {
+		if !offered[art.GUID] {
+			data.Candidates = append(data.Candidates, art)
+		}
+	}
+
+	funcMap := template.FuncMap{"articleURL": articleURL, "join": strings.Join}
+	tmpl, err := template.New("prompt").Funcs(funcMap).Parse(promptTemplate)
+	if err != nil {
+		http.Error(w, "Error parsing template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+	}
+}
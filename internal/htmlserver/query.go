@@ -0,0 +1,120 @@
+package htmlserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// DATE_FORMAT is the format of the after and before query parameters.
+const DATE_FORMAT = "2006-01-02"
+
+// Orders the articles can be listed in.
+const (
+	ORDER_ASC  = "asc"
+	ORDER_DESC = "desc"
+)
+
+// articleQuery parses the query parameters selecting a page of articles, shared by the article
+// list and the API:
+//
+//	q                    text to search for
+//	tag, feed, model     exact matches
+//	min_score, max_score score range, inclusive
+//	after, before        publication date range as YYYY-MM-DD, inclusive
+//	reported, scored,
+//	read, starred        true or false
+//	sort, order          one of storage.SORTS, and asc or desc
+//	cursor, limit        paging
+func articleQuery(r *http.Request, defaultLimit int) (storage.ArticleQuery, error) {
+	params := r.URL.Query()
+	q := storage.ArticleQuery{
+		Search:  strings.TrimSpace(params.Get("q")),
+		Tag:     storage.NormalizeTag(params.Get("tag")),
+		FeedURL: params.Get("feed"),
+		Model:   params.Get("model"),
+		Sort:    params.Get("sort"),
+		Cursor:  params.Get("cursor"),
+		Limit:   defaultLimit,
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > storage.MAX_QUERY_LIMIT {
+			return q, fmt.Errorf("limit must be between 1 and %d", storage.MAX_QUERY_LIMIT)
+		}
+		q.Limit = limit
+	}
+
+	flags := []struct {
+		name  string
+		value **bool
+	}{
+		{"reported", &q.Reported},
+		{"scored", &q.Scored},
+		{"read", &q.Read},
+		{"starred", &q.Starred},
+	}
+	for _, flag := range flags {
+		var err error
+		if *flag.value, err = queryBool(r, flag.name); err != nil {
+			return q, err
+		}
+	}
+
+	scores := []struct {
+		name  string
+		value **int
+	}{
+		{"min_score", &q.MinScore},
+		{"max_score", &q.MaxScore},
+	}
+	for _, score := range scores {
+		v := params.Get(score.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return q, fmt.Errorf("%s must be a number, not %q", score.name, v)
+		}
+		*score.value = &n
+	}
+
+	dates := []struct {
+		name  string
+		value *time.Time
+		days  int
+	}{
+		{"after", &q.PublishedAfter, 0},
+		// The query's end is exclusive, so the day after is used to include the whole day.
+		{"before", &q.PublishedBefore, 1},
+	}
+	for _, date := range dates {
+		v := params.Get(date.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(DATE_FORMAT, v)
+		if err != nil {
+			return q, fmt.Errorf("%s must be a date as YYYY-MM-DD, not %q", date.name, v)
+		}
+		*date.value = t.AddDate(0, 0, date.days)
+	}
+
+	if !storage.ValidSort(q.Sort) {
+		return q, fmt.Errorf("sort must be one of %s, not %q", strings.Join(storage.SORTS, ", "), q.Sort)
+	}
+	switch order := params.Get("order"); order {
+	case "", ORDER_DESC:
+	case ORDER_ASC:
+		q.Ascending = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc, not %q", order)
+	}
+	return q, nil
+}
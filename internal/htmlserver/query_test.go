@@ -0,0 +1,87 @@
+package htmlserver
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// ptr returns a pointer to the value.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestArticleQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  storage.ArticleQuery
+	}{
+		{"defaults", "", storage.ArticleQuery{Limit: 50}},
+		{"search is trimmed", "q=+z80+emulator+", storage.ArticleQuery{Search: "z80 emulator", Limit: 50}},
+		{"tag is normalized", "tag=Retro+Computing", storage.ArticleQuery{Tag: "retro-computing", Limit: 50}},
+		{
+			"exact matches",
+			"feed=https%3A%2F%2Fexample.com%2Ffeed&model=gemini-3-flash",
+			storage.ArticleQuery{FeedURL: "https://example.com/feed", Model: "gemini-3-flash", Limit: 50},
+		},
+		{
+			"flags",
+			"reported=true&scored=false&read=1&starred=0",
+			storage.ArticleQuery{Reported: ptr(true), Scored: ptr(false), Read: ptr(true), Starred: ptr(false), Limit: 50},
+		},
+		{"score range", "min_score=50&max_score=-1", storage.ArticleQuery{MinScore: ptr(50), MaxScore: ptr(-1), Limit: 50}},
+		{
+			"dates include the whole of the before day",
+			"after=2024-01-01&before=2024-01-31",
+			storage.ArticleQuery{
+				PublishedAfter:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				PublishedBefore: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				Limit:           50,
+			},
+		},
+		{"sort ascending", "sort=score&order=asc", storage.ArticleQuery{Sort: storage.SORT_SCORE, Ascending: true, Limit: 50}},
+		{"sort descending", "sort=title&order=desc", storage.ArticleQuery{Sort: storage.SORT_TITLE, Limit: 50}},
+		{"paging", "cursor=abc&limit=500", storage.ArticleQuery{Cursor: "abc", Limit: 500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/articles?"+tt.query, nil)
+			got, err := articleQuery(r, 50)
+			if err != nil {
+				t.Fatalf("articleQuery(%q): %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("articleQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArticleQueryErrors(t *testing.T) {
+	tests := []string{
+		"limit=0",
+		"limit=-5",
+		"limit=501",
+		"limit=ten",
+		"reported=maybe",
+		"scored=yes",
+		"read=2",
+		"starred=no",
+		"min_score=high",
+		"max_score=1.5",
+		"after=01/02/2024",
+		"before=2024-13-01",
+		"sort=popularity",
+		"order=up",
+	}
+	for _, query := range tests {
+		r := httptest.NewRequest("GET", "/articles?"+query, nil)
+		if _, err := articleQuery(r, 50); err == nil {
+			t.Errorf("articleQuery(%q) succeeded, want an error", query)
+		}
+	}
+}
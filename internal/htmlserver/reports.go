@@ -0,0 +1,245 @@
+package htmlserver
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// MAX_REPORTS is the number of past reports listed on the reports page.
+const MAX_REPORTS = 200
+
+const reportsTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>AI RSS Scraper - Reports</title>
+	<style>
+		body { font-family: sans-serif; margin: 2em; }
+		nav { margin-bottom: 1em; }
+		table { width: 100%; border-collapse: collapse; }
+		th, td { text-align: left; padding: 8px; border-bottom: 1px solid #ddd; }
+		th { background-color: #f2f2f2; }
+		.status-sent { color: green; }
+		.status-partial { color: #d68910; }
+		.status-failed { color: #c0392b; font-weight: bold; }
+		.dest { color: #555; font-size: 0.85em; }
+	</style>
+</head>
+<body>
+	<nav><a href="/">Articles</a> | <b>Reports</b> | <a href="/schedule">Schedule</a> | <a href="/prompt">Prompt</a></nav>
+	<h1>Reports</h1>
+	{{if .}}
+	<table>
+		<thead>
+			<tr>
+				<th>#</th>
+				<th>Date</th>
+				<th>Title</th>
+				<th>Articles</th>
+				<th>Status</th>
+				<th>Destinations</th>
+			</tr>
+		</thead>
+		<tbody>
+			{{range .}}
+			<tr>
+				<td>{{.ID}}</td>
+				<td>{{.CreatedAt.Local.Format "2006-01-02 15:04"}}</td>
+				<td><a href="/reports/{{.ID}}">{{.Title}}</a></td>
+				<td>{{.ArticleCount}}</td>
+				<td class="status-{{.Status}}">{{.Status}}</td>
+				<td class="dest">{{join .Destinations ", "}}</td>
+			</tr>
+			{{end}}
+		</tbody>
+	</table>
+	{{else}}
+	<p>No reports have been generated yet.</p>
+	{{end}}
+</body>
+</html>
+`
+
+const reportTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>AI RSS Scraper - Report {{.Report.ID}}</title>
+	<style>
+		body { font-family: sans-serif; margin: 2em; }
+		nav { margin-bottom: 1em; }
+		table { border-collapse: collapse; margin-bottom: 1.5em; }
+		th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; vertical-align: top; }
+		th { background-color: #f2f2f2; }
+		.status-ok, .status-sent { color: green; }
+		.status-partial { color: #d68910; }
+		.status-failed { color: #c0392b; font-weight: bold; }
+		.error { color: #c0392b; font-size: 0.85em; }
+		.related { padding-left: 1.5em; }
+		iframe { width: 100%; height: 80vh; border: 1px solid #ddd; }
+	</style>
+</head>
+<body>
+	<nav><a href="/">Articles</a> | <a href="/reports">Reports</a> | <a href="/schedule">Schedule</a> | <a href="/prompt">Prompt</a></nav>
+	<h1>{{.Report.Title}}</h1>
+	<table>
+		<tr><th>Report</th><td>#{{.Report.ID}}</td></tr>
+		<tr><th>Generated</th><td>{{.Report.CreatedAt.Local.Format "2006-01-02 15:04"}}</td></tr>
+		<tr><th>Status</th><td class="status-{{.Report.Status}}">{{.Report.Status}}</td></tr>
+		{{if .Report.Error}}<tr><th>Error</th><td class="error">{{.Report.Error}}</td></tr>{{end}}
+	</table>
+
+	{{if .Deliveries}}
+	<h2>Deliveries</h2>
+	<table>
+		<tr><th>Time</th><th>Destination</th><th>Status</th><th>Articles</th></tr>
+		{{range .Deliveries}}
+		<tr>
+			<td>{{.AttemptedAt.Local.Format "2006-01-02 15:04:05"}}</td>
+			<td>{{.Destination}}</td>
+			<td class="status-{{.Status}}">{{.Status}}{{if .Error}} <span class="error">{{.Error}}</span>{{end}}</td>
+			<td>{{.Articles}}</td>
+		</tr>
+		{{end}}
+	</table>
+	{{end}}
+
+	<h2>Articles</h2>
+	<ul>
+		{{range .Articles}}
+		<li>
+			<a href="{{articleURL .GUID}}">{{if .TranslatedTitle}}{{.TranslatedTitle}}{{else}}{{.Title}}{{end}}</a> ({{.Score}})
+			{{if .Related}}
+			<ul class="related">
+				{{range .Related}}<li><a href="{{articleURL .GUID}}">{{if .TranslatedTitle}}{{.TranslatedTitle}}{{else}}{{.Title}}{{end}}</a> ({{.Score}})</li>{{end}}
+			</ul>
+			{{end}}
+		</li>
+		{{end}}
+	</ul>
+
+	{{if .HasHTML}}
+	<h2>As Delivered</h2>
+	<iframe src="/reports/{{.Report.ID}}/html" sandbox="allow-popups allow-popups-to-escape-sandbox"></iframe>
+	{{end}}
+</body>
+</html>
+`
+
+// ReportData is the data rendered by the report page.
+type ReportData struct {
+	Report     *storage.Report
+	Deliveries []storage.Delivery
+	Articles   []storage.Article
+	HasHTML    bool
+}
+
+func (s *Server) handleReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := s.db.ListReports(MAX_REPORTS)
+	if err != nil {
+		http.Error(w, "Error fetching reports: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.New("reports").Funcs(template.FuncMap{"join": strings.Join}).Parse(reportsTemplate)
+	if err != nil {
+		http.Error(w, "Error parsing template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.Execute(w, reports); err != nil {
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// reportFromPath returns the report whose ID is in the request path, writing an error
+// response and returning nil if there is no such report.
+func (s *Server) reportFromPath(w http.ResponseWriter, r *http.Request) *storage.Report {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	rep, err := s.db.GetReport(id)
+	if err != nil {
+		http.Error(w, "Error fetching report: "+err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	if rep == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	return rep
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	rep := s.reportFromPath(w, r)
+	if rep == nil {
+		return
+	}
+
+	deliveries, err := s.db.GetReportDeliveries(rep.ID)
+	if err != nil {
+		http.Error(w, "Error fetching deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	articles, err := s.db.GetReportArticles(rep.ID)
+	if err != nil {
+		http.Error(w, "Error fetching articles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	html, err := s.db.GetReportHTML(rep.ID)
+	if err != nil {
+		http.Error(w, "Error fetching report: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{"articleURL": articleURL}).Parse(reportTemplate)
+	if err != nil {
+		http.Error(w, "Error parsing template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := ReportData{
+		Report:     rep,
+		Deliveries: deliveries,
+		Articles:   articles,
+		HasHTML:    html != "",
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleReportHTML serves the report exactly as it was rendered when it was generated.
+func (s *Server) handleReportHTML(w http.ResponseWriter, r *http.Request) {
+	rep := s.reportFromPath(w, r)
+	if rep == nil {
+		return
+	}
+
+	html, err := s.db.GetReportHTML(rep.ID)
+	if err != nil {
+		http.Error(w, "Error fetching report: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if html == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// The stored report is a complete document of its own; keep it from running scripts.
+	w.Header().Set("Content-Security-Policy", "script-src 'none'")
+	_, _ = w.Write([]byte(html))
+}
@@ -0,0 +1,223 @@
+package htmlserver
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/schedule"
+)
+
+const scheduleTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>AI RSS Scraper - Schedule</title>
+	<style>
+		body { font-family: sans-serif; margin: 2em; }
+		nav { margin-bottom: 1em; }
+		table { width: 100%; border-collapse: collapse; }
+		th, td { text-align: left; padding: 8px; border-bottom: 1px solid #ddd; }
+		th { background-color: #f2f2f2; }
+		.spec { font-family: monospace; }
+		.state { color: #d68910; }
+		.error { color: #c0392b; }
+		.never { color: #888; }
+		.ok { color: green; }
+		h2 { margin-top: 1.5em; }
+		.actions { margin-bottom: 1em; padding: 1em; background: #eee; border-radius: 4px; }
+		.actions form { display: inline; }
+		button { padding: 0.5em 1em; cursor: pointer; margin-right: 0.5em; }
+		pre { background: #f8f8f8; padding: 0.5em; max-height: 30em; overflow: auto; white-space: pre-wrap; }
+	</style>
+</head>
+<body>
+	<nav><a href="/">Articles</a> | <a href="/reports">Reports</a> | <b>Schedule</b> | <a href="/prompt">Prompt</a></nav>
+	<h1>Schedule</h1>
+	{{if .Runnable}}
+	<div class="actions">
+		<form action="/run/fetch" method="POST"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><button type="submit">Fetch now</button></form>
+		<form action="/run/score" method="POST"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><button type="submit">Score now</button></form>
+		<form action="/run/report" method="POST"><input type="hidden" name="csrf_token" value="{{.CSRFToken}}"><button type="submit">Send report now</button></form>
+		{{if .Pending}}<span class="state">Queued: {{join .Pending ", "}}</span>{{end}}
+	</div>
+	{{end}}
+	{{if .Stages}}
+	<h2>Health</h2>
+	<p>Failure budget: {{if gt .Budget 0}}{{.Budget}} consecutive failures of a stage{{else}}unlimited{{end}}</p>
+	<table>
+		<thead>
+			<tr>
+				<th>Stage</th>
+				<th>Runs</th>
+				<th>Failures</th>
+				<th>Consecutive Failures</th>
+				<th>Last Run</th>
+				<th>Last Success</th>
+				<th>Last Error</th>
+			</tr>
+		</thead>
+		<tbody>
+			{{range .Stages}}
+			<tr>
+				<td>{{.Name}}</td>
+				<td>{{.Runs}}</td>
+				<td>{{.Failures}}</td>
+				<td class="{{if .ConsecutiveFailures}}error{{else}}ok{{end}}">{{.ConsecutiveFailures}}</td>
+				<td>{{.LastRun.Format "2006-01-02 15:04 MST"}}</td>
+				<td>{{if .LastSuccess.IsZero}}<span class="never">never</span>{{else}}{{.LastSuccess.Format "2006-01-02 15:04 MST"}}{{end}}</td>
+				<td class="error">{{.LastError}}</td>
+			</tr>
+			{{end}}
+		</tbody>
+	</table>
+	{{end}}
+	{{if .Jobs}}
+	<h2>Jobs</h2>
+	<table>
+		<thead>
+			<tr>
+				<th>Job</th>
+				<th>Schedule</th>
+				<th>Timezone</th>
+				<th>Next Run</th>
+				<th>Last Run</th>
+				<th>Result</th>
+			</tr>
+		</thead>
+		<tbody>
+			{{range .Jobs}}
+			<tr>
+				<td>{{.Name}}</td>
+				<td class="spec">{{.Spec}}</td>
+				<td>{{.Location}}</td>
+				<td>{{if .Next.IsZero}}<span class="never">never</span>{{else}}{{.Next.Format "2006-01-02 15:04 MST"}} ({{until .Next}}){{end}}</td>
+				<td>{{if .LastStart.IsZero}}<span class="never">not yet</span>{{else}}{{.LastStart.Format "2006-01-02 15:04 MST"}}, took {{round .LastDuration}}{{end}}</td>
+				<td>
+					{{if .Running}}<span class="state">running</span>
+					{{else if .Queued}}<span class="state">queued</span>
+					{{else if .LastError}}<span class="error">{{.LastError}}</span>
+					{{else if .Runs}}ok{{end}}
+				</td>
+			</tr>
+			{{end}}
+		</tbody>
+	</table>
+	{{end}}
+	{{if .Runs}}
+	<h2>Recent Runs</h2>
+	<table>
+		<thead>
+			<tr>
+				<th>Stage</th>
+				<th>Started By</th>
+				<th>Started</th>
+				<th>Duration</th>
+				<th>Result</th>
+			</tr>
+		</thead>
+		<tbody>
+			{{range .Runs}}
+			<tr>
+				<td>{{.Stage}}</td>
+				<td>{{.Trigger}}</td>
+				<td>{{.Started.Format "2006-01-02 15:04:05 MST"}}</td>
+				<td>{{round .Duration}}</td>
+				<td>
+					{{if .Running}}<span class="state">running</span>
+					{{else if .Error}}<span class="error">{{.Error}}</span>
+					{{else}}<span class="ok">ok</span>{{end}}
+				</td>
+			</tr>
+			{{if .Output}}
+			<tr>
+				<td colspan="5"><details><summary>Output</summary><pre>{{.Output}}</pre></details></td>
+			</tr>
+			{{end}}
+			{{end}}
+		</tbody>
+	</table>
+	{{end}}
+	{{if and (not .Jobs) (not .Stages) (not .Runnable)}}
+	<p>No jobs are scheduled. Start the server with <code>run --serve</code> and one or more <code>--schedule-*</code> options.</p>
+	{{end}}
+</body>
+</html>
+`
+
+// ScheduleData is the data rendered by the schedule page.
+type ScheduleData struct {
+	Jobs   []schedule.JobStatus
+	Stages []schedule.StageHealth
+	Budget int
+
+	// Runnable is true if stages can be run from the page.
+	Runnable bool
+	Pending  []string
+	Runs     []schedule.Run
+
+	CSRFToken string
+}
+
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	var data ScheduleData
+	if s.Scheduler != nil {
+		data.Jobs = s.Scheduler.Jobs()
+	}
+	if s.Health != nil {
+		data.Stages = s.Health.Stages()
+		data.Budget = s.Health.Budget()
+	}
+	if s.Runner != nil {
+		data.Runnable = true
+		data.Pending = s.Runner.Pending()
+		data.Runs = s.Runner.History()
+		data.CSRFToken = csrfToken(w, r)
+	}
+
+	funcMap := template.FuncMap{
+		"until": func(t time.Time) string {
+			return time.Until(t).Round(time.Minute).String()
+		},
+		"round": func(d time.Duration) string {
+			return d.Round(time.Second).String()
+		},
+		"join": strings.Join,
+	}
+
+	tmpl, err := template.New("schedule").Funcs(funcMap).Parse(scheduleTemplate)
+	if err != nil {
+		http.Error(w, "Error parsing template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleRun queues a run of a stage of the scraper, and returns to the schedule page.
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if s.Runner == nil {
+		http.Error(w, "Stages can only be run from the web server started by run --serve", http.StatusServiceUnavailable)
+		return
+	}
+
+	err := s.Runner.Trigger(r.PathValue("stage"))
+	switch {
+	case errors.Is(err, schedule.ErrUnknownJob):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, schedule.ErrAlreadyQueued):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Error queueing run: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/schedule", http.StatusSeeOther)
+}
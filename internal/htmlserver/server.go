@@ -1,37 +1,123 @@
 package htmlserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/schedule"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
 )
 
+// SHUTDOWN_TIMEOUT is how long the server waits for requests in progress to finish when
+// shutting down.
+const SHUTDOWN_TIMEOUT = 10 * time.Second
+
+// DEFAULT_LIST_LIMIT is the number of articles on each page of the article list.
+const DEFAULT_LIST_LIMIT = 100
+
 type Server struct {
 	host string
 	port int
 	db   *storage.DB
+
+	// FeedThreshold and FeedAgeDays select the articles published in the feeds, unless the
+	// request overrides them.
+	FeedThreshold int
+	FeedAgeDays   int
+
+	// FeedURL is the feed configured by --feed-url, which cannot be removed through the API.
+	FeedURL string
+
+	// Scheduler, if set, is the scheduler running jobs in this process, and Health the
+	// failures of each stage of the scraper. Both are shown on the schedule page.
+	Scheduler *schedule.Scheduler
+	Health    *schedule.Health
+
+	// Runner, if set, runs the stages of the scraper on request from the schedule page.
+	Runner Runner
+
+	// Scorer, if set, scores articles on request from the article page.
+	Scorer Scorer
+
+	// Auth authenticates requests. If nil, they are not authenticated.
+	Auth *Auth
+}
+
+// Runner queues runs of the stages of the scraper (fetch, score, report...), and reports on
+// the recent runs.
+type Runner interface {
+	// Trigger queues a run of the named stage. It returns an error wrapping
+	// schedule.ErrUnknownJob or schedule.ErrAlreadyQueued if the run cannot be queued.
+	Trigger(stage string) error
+	// Pending returns the stages queued to run.
+	Pending() []string
+	// History returns the recent runs, most recent first.
+	History() []schedule.Run
 }
 
 func NewServer(host string, port int, db *storage.DB) *Server {
 	return &Server{
-		host: host,
-		port: port,
-		db:   db,
+		host:          host,
+		port:          port,
+		db:            db,
+		FeedThreshold: DEFAULT_FEED_THRESHOLD,
+		FeedAgeDays:   DEFAULT_FEED_AGE_DAYS,
 	}
 }
 
-func (s *Server) Start() error {
+// Start serves the web interface until the context is cancelled, then shuts down
+// gracefully, letting requests in progress finish.
+func (s *Server) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleList)
 	mux.HandleFunc("/action", s.handleAction)
+	mux.HandleFunc("GET /article", s.handleArticleRedirect)
+	mux.HandleFunc("GET /article/{guid}", s.handleArticle)
+	mux.HandleFunc("POST /article/{guid}", s.handleArticleAction)
+	mux.HandleFunc("GET /prompt", s.handlePrompt)
+	mux.HandleFunc("POST /prompt", s.handlePromptAction)
+	mux.HandleFunc("GET /reports", s.handleReports)
+	mux.HandleFunc("GET /reports/{id}", s.handleReport)
+	mux.HandleFunc("GET /reports/{id}/html", s.handleReportHTML)
+	mux.HandleFunc("GET /schedule", s.handleSchedule)
+	mux.HandleFunc("POST /run/{stage}", s.handleRun)
+	mux.HandleFunc("GET /feed.xml", s.feedHandler(report.FORMAT_ATOM))
+	mux.HandleFunc("GET /rss.xml", s.feedHandler(report.FORMAT_RSS))
+	mux.HandleFunc("GET /feed.json", s.feedHandler(report.FORMAT_JSONFEED))
+	s.registerAPI(mux)
 
 	addr := fmt.Sprintf("%s:%d", s.host, s.port)
-	log.Printf("Starting web server at http://%s", addr)
-	return http.ListenAndServe(addr, mux)
+	handler := http.NewCrossOriginProtection().Handler(s.requireAuth(requireCSRF(mux)))
+	srv := &http.Server{Addr: addr, Handler: handler}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("Starting web server at http://%s", addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down web server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error shutting down web server: %w", err)
+	}
+	return nil
 }
 
 const listTemplate = `
@@ -40,6 +126,9 @@ const listTemplate = `
 <head>
 	<meta charset="UTF-8">
 	<title>AI RSS Scraper - Articles</title>
+	<link rel="alternate" type="application/atom+xml" title="Top articles (Atom)" href="/feed.xml">
+	<link rel="alternate" type="application/rss+xml" title="Top articles (RSS)" href="/rss.xml">
+	<link rel="alternate" type="application/feed+json" title="Top articles (JSON Feed)" href="/feed.json">
 	<style>
 		body { font-family: sans-serif; margin: 2em; }
 		table { width: 100%; border-collapse: collapse; }
@@ -50,6 +139,19 @@ const listTemplate = `
 		.score-high { color: green; font-weight: bold; }
 		.score-low { color: #888; }
 		.filter { font-size: 0.9em; }
+		.original { color: #777; font-size: 0.85em; }
+		.lang { text-transform: uppercase; font-size: 0.8em; border: 1px solid #ccc; border-radius: 3px; padding: 0 0.3em; }
+		.dup { color: #888; font-size: 0.8em; font-style: italic; }
+		.details { text-decoration: none; color: #888; font-size: 0.9em; }
+		.tagbar { margin-bottom: 1em; }
+		.tag { display: inline-block; background: #eaf2fb; color: #2c3e50; border-radius: 1em; padding: 0.1em 0.7em; margin: 0 0.3em 0.3em 0; font-size: 0.8em; text-decoration: none; }
+		.tag.active { background: #3498db; color: #fff; }
+		.filters { margin-bottom: 1em; padding: 1em; background: #f7f7f7; border-radius: 4px; font-size: 0.9em; }
+		.filters label { margin-right: 1em; white-space: nowrap; }
+		.filters input[type=number] { width: 4em; }
+		th a { color: inherit; text-decoration: none; }
+		.paging { margin-top: 1em; }
+		.paging a { margin-right: 1em; }
 	</style>
 	<script>
 		function toggleAll(source) {
@@ -58,32 +160,66 @@ const listTemplate = `
 				checkboxes[i].checked = source.checked;
 			}
 		}
-		function updateFilter() {
-			var reported = document.getElementById('reportedOnly').checked;
-			window.location.href = "/?reported=" + reported;
-		}
 	</script>
 </head>
 <body>
-	<h1>Articles</h1>
+	<nav style="margin-bottom: 1em;"><b>Articles</b> | <a href="/reports">Reports</a> | <a href="/schedule">Schedule</a> | <a href="/prompt">Prompt</a></nav>
+	<h1>Articles{{if .Tag}} tagged "{{.Tag}}"{{end}}</h1>
+	{{if .Tags}}
+	<div class="tagbar">
+		<a class="tag{{if not .Tag}} active{{end}}" href="{{.URL "tag" ""}}">all</a>
+		{{range .Tags}}<a class="tag{{if eq .Tag $.Tag}} active{{end}}" href="{{$.URL "tag" .Tag}}">{{.Tag}} ({{.Count}})</a>{{end}}
+	</div>
+	{{end}}
+	<form class="filters" action="/" method="GET">
+		<label>Search <input type="search" name="q" value="{{.Get "q"}}"></label>
+		<label>Score <input type="number" name="min_score" value="{{.Get "min_score"}}" placeholder="min"> to <input type="number" name="max_score" value="{{.Get "max_score"}}" placeholder="max"></label>
+		<label>Feed
+			<select name="feed">
+				<option value="">any</option>
+				{{range .Feeds}}<option value="{{.URL}}"{{if eq .URL ($.Get "feed")}} selected{{end}}>{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</option>{{end}}
+			</select>
+		</label>
+		<label>Model
+			<select name="model">
+				<option value="">any</option>
+				{{range .Models}}<option{{if eq . ($.Get "model")}} selected{{end}}>{{.}}</option>{{end}}
+			</select>
+		</label>
+		<label>Published <input type="date" name="after" value="{{.Get "after"}}"> to <input type="date" name="before" value="{{.Get "before"}}"></label>
+		{{range .Flags}}
+		<label>{{.Label}}
+			<select name="{{.Name}}">
+				<option value="">any</option>
+				<option value="true"{{if eq ($.Get .Name) "true"}} selected{{end}}>yes</option>
+				<option value="false"{{if eq ($.Get .Name) "false"}} selected{{end}}>no</option>
+			</select>
+		</label>
+		{{end}}
+		{{if .Tag}}<input type="hidden" name="tag" value="{{.Tag}}">{{end}}
+		{{if .Get "sort"}}<input type="hidden" name="sort" value="{{.Get "sort"}}">{{end}}
+		{{if .Get "order"}}<input type="hidden" name="order" value="{{.Get "order"}}">{{end}}
+		<button type="submit">Filter</button>
+		<a href="/">Clear</a>
+	</form>
 	<form action="/action" method="POST">
+		<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+		<input type="hidden" name="return" value="{{.URL}}">
 		<div class="actions">
 			<div>
 				<button type="submit" name="action" value="rescore">Rescore Selected</button>
 				<button type="submit" name="action" value="reset-reported">Reset Reported Status</button>
 			</div>
-			<div class="filter">
-				<input type="checkbox" id="reportedOnly" onclick="updateFilter()" {{if .ReportedOnly}}checked{{end}}>
-				<label for="reportedOnly">Show Reported Only</label>
-			</div>
 		</div>
 		<table>
 			<thead>
 				<tr>
 					<th><input type="checkbox" onClick="toggleAll(this)"></th>
-					<th>Score</th>
-					<th>Title</th>
-					<th>Date</th>
+					<th><a href="{{.SortURL "score"}}">Score{{.SortMark "score"}}</a></th>
+					<th><a href="{{.SortURL "title"}}">Title{{.SortMark "title"}}</a></th>
+					<th>Tags</th>
+					<th><a href="{{.SortURL "date"}}">Date{{.SortMark "date"}}</a></th>
+					<th><a href="{{.SortURL "feed"}}">Feed{{.SortMark "feed"}}</a></th>
 					<th>Reported</th>
 				</tr>
 			</thead>
@@ -98,21 +234,145 @@ const listTemplate = `
 							-
 						{{end}}
 					</td>
-					<td><a href="{{.Link}}" target="_blank">{{.Title}}</a></td>
+					<td>
+						{{if .TranslatedTitle}}
+							<a href="{{.Link}}" target="_blank">{{.TranslatedTitle}}</a>
+							<div class="original">{{.Title}} <span class="lang">{{.Language}}</span></div>
+						{{else}}
+							<a href="{{.Link}}" target="_blank">{{.Title}}</a>
+						{{end}}
+						<a class="details" href="{{articleURL .GUID}}" title="Details">&#9432;</a>
+						{{if .IsDuplicate}}<span class="dup" title="Duplicate of {{.ClusterID}}">duplicate</span>{{end}}
+					</td>
+					<td>{{range .Tags}}<a class="tag" href="{{$.URL "tag" .}}">{{.}}</a>{{end}}</td>
 					<td>{{.PublishedDate.Format "2006-01-02 15:04"}}</td>
+					<td>{{$.FeedName .FeedURL}}</td>
 					<td>{{if .Reported}}Yes{{else}}No{{end}}</td>
 				</tr>
+				{{else}}
+				<tr><td colspan="7">No articles match.</td></tr>
 				{{end}}
 			</tbody>
 		</table>
 	</form>
+	<div class="paging">
+		{{if .Get "cursor"}}<a href="{{.URL "cursor" ""}}">&laquo; First page</a>{{end}}
+		{{if .Next}}<a href="{{.URL "cursor" .Next}}">Next page &raquo;</a>{{end}}
+	</div>
 </body>
 </html>
 `
 
 type ListData struct {
-	Articles     []storage.Article
-	ReportedOnly bool
+	Articles []storage.Article
+	Tag      string
+	Tags     []storage.TagCount
+	Feeds    []storage.Feed
+	Models   []string
+
+	// Params are the query parameters selecting the page, and Next the cursor for the next
+	// page, if there is one.
+	Params url.Values
+	Next   string
+
+	CSRFToken string
+}
+
+// ListFlag is a yes/no filter on the list page.
+type ListFlag struct {
+	Name  string
+	Label string
+}
+
+// Flags returns the yes/no filters on the list page.
+func (d ListData) Flags() []ListFlag {
+	return []ListFlag{{"reported", "Reported"}, {"scored", "Scored"}}
+}
+
+// Get returns the value of one of the query parameters selecting the page.
+func (d ListData) Get(name string) string {
+	return d.Params.Get(name)
+}
+
+// URL returns the URL of the first page of the list with the same filters and sort order, but
+// with each name given in pairs set to the value that follows it, or removed if the value is
+// empty.
+func (d ListData) URL(pairs ...string) string {
+	params := url.Values{}
+	for name, values := range d.Params {
+		if name != "cursor" {
+			params[name] = values
+		}
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			params.Del(pairs[i])
+		} else {
+			params.Set(pairs[i], pairs[i+1])
+		}
+	}
+	if len(params) == 0 {
+		return "/"
+	}
+	return "/?" + params.Encode()
+}
+
+// sorting returns the column the list is sorted by, and the order.
+func (d ListData) sorting() (string, string) {
+	sort, order := d.Get("sort"), d.Get("order")
+	if sort == "" {
+		sort = storage.SORT_DATE
+	}
+	if order == "" {
+		order = ORDER_DESC
+	}
+	return sort, order
+}
+
+// SortURL returns the URL of the list sorted by the column, reversing the order if it is
+// already sorted by that column. Text columns sort ascending first, and the rest descending.
+func (d ListData) SortURL(column string) string {
+	sort, order := d.sorting()
+	switch {
+	case column == sort && order == ORDER_DESC:
+		order = ORDER_ASC
+	case column == sort:
+		order = ORDER_DESC
+	case column == storage.SORT_TITLE || column == storage.SORT_FEED:
+		order = ORDER_ASC
+	default:
+		order = ORDER_DESC
+	}
+	if column == storage.SORT_DATE {
+		column = ""
+	}
+	if order == ORDER_DESC {
+		order = ""
+	}
+	return d.URL("sort", column, "order", order)
+}
+
+// SortMark returns the arrow shown next to the column the list is sorted by.
+func (d ListData) SortMark(column string) string {
+	sort, order := d.sorting()
+	switch {
+	case column != sort:
+		return ""
+	case order == ORDER_ASC:
+		return " \u25b2"
+	default:
+		return " \u25bc"
+	}
+}
+
+// FeedName returns the title of the feed, or its URL if it has none.
+func (d ListData) FeedName(feedURL string) string {
+	for _, feed := range d.Feeds {
+		if feed.URL == feedURL && feed.Title != "" {
+			return feed.Title
+		}
+	}
+	return feedURL
 }
 
 func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
@@ -121,15 +381,39 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reportedOnly := r.URL.Query().Get("reported") == "true"
-
-	// Fetch recent articles (limit 100 for now to keep it snappy)
-	articles, err := s.db.ListArticlesFiltered(100, reportedOnly)
+	q, err := articleQuery(r, DEFAULT_LIST_LIMIT)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	articles, next, err := s.db.QueryArticles(q)
+	if errors.Is(err, storage.ErrBadCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error fetching articles: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	tags, err := s.db.ListTags()
+	if err != nil {
+		http.Error(w, "Error fetching tags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	feeds, err := s.db.ListFeeds()
+	if err != nil {
+		http.Error(w, "Error fetching feeds: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	models, err := s.db.ListModels()
+	if err != nil {
+		http.Error(w, "Error fetching models: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	funcMap := template.FuncMap{
 		"toInt": func(s string) int {
 			i, _ := strconv.Atoi(s)
@@ -138,6 +422,7 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 		"ge": func(a, b int) bool {
 			return a >= b
 		},
+		"articleURL": articleURL,
 	}
 
 	tmpl, err := template.New("list").Funcs(funcMap).Parse(listTemplate)
@@ -147,8 +432,14 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := ListData{
-		Articles:     articles,
-		ReportedOnly: reportedOnly,
+		Articles:  articles,
+		Tag:       q.Tag,
+		Tags:      tags,
+		Feeds:     feeds,
+		Models:    models,
+		Params:    r.URL.Query(),
+		Next:      next,
+		CSRFToken: csrfToken(w, r),
 	}
 
 	if err := tmpl.Execute(w, data); err != nil {
@@ -171,7 +462,7 @@ func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
 	guids := r.Form["guids"]
 
 	if len(guids) == 0 {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		http.Redirect(w, r, returnURL(r), http.StatusSeeOther)
 		return
 	}
 
@@ -191,5 +482,15 @@ func (s *Server) handleAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, returnURL(r), http.StatusSeeOther)
+}
+
+// returnURL returns the page of the article list that an action was submitted from, so that
+// its filters are kept. Only paths on this site are followed.
+func returnURL(r *http.Request) string {
+	u := r.FormValue("return")
+	if !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") || strings.Contains(u, "\\") {
+		return "/"
+	}
+	return u
 }
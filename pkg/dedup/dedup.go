@@ -0,0 +1,175 @@
+package dedup
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MAX_DUPLICATE_DISTANCE is the largest Hamming distance between two fingerprints for the
+// articles to be considered the same story.
+const MAX_DUPLICATE_DISTANCE = 3
+
+// trackingParams are query parameters that only identify where a click came from, and
+// never change the content of the page.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"ref_src": true,
+}
+
+// CanonicalizeURL normalizes a link so that the same page reached through different feeds
+// compares equal: the scheme is forced to https, the host is lowercased without "www." or a
+// default port, tracking parameters and the fragment are removed, the remaining parameters
+// are sorted, and any trailing slash is dropped. Links that cannot be parsed are returned
+// unchanged.
+func CanonicalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = "https"
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+			query.Del(key)
+		}
+	}
+	// Encode sorts by key, which makes the parameter order irrelevant.
+	u.RawQuery = query.Encode()
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// tokenize splits text into lowercase words.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// SimHash computes a 64-bit locality-sensitive fingerprint of the text. Texts that share
+// most of their words and word pairs produce fingerprints that differ in only a few bits.
+// It returns 0 for text without any words.
+func SimHash(text string) uint64 {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return 0
+	}
+
+	features := make([]string, 0, 2*len(words))
+	features = append(features, words...)
+	for i := 0; i+1 < len(words); i++ {
+		features = append(features, words[i]+" "+words[i+1])
+	}
+
+	var weights [64]int
+	for _, feature := range features {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+	return fingerprint
+}
+
+// Distance returns the number of bits that differ between two fingerprints.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Entry is an article that has already been seen, as held by an Index.
+type Entry struct {
+	ClusterID    string
+	CanonicalURL string
+	Fingerprint  uint64
+}
+
+// Index finds previously seen articles that are duplicates of a new one.
+type Index struct {
+	byURL   map[string]string
+	entries []Entry
+}
+
+// NewIndex creates an Index from previously seen articles.
+func NewIndex(entries []Entry) *Index {
+	idx := &Index{byURL: make(map[string]string)}
+	for _, e := range entries {
+		idx.Add(e)
+	}
+	return idx
+}
+
+// Add records an article so that later articles can be matched against it.
+func (idx *Index) Add(e Entry) {
+	if e.CanonicalURL != "" {
+		if _, ok := idx.byURL[e.CanonicalURL]; !ok {
+			idx.byURL[e.CanonicalURL] = e.ClusterID
+		}
+	}
+	if e.Fingerprint != 0 {
+		idx.entries = append(idx.entries, e)
+	}
+}
+
+// Find returns the cluster ID of an earlier article with the same canonical URL, or failing
+// that the closest one whose fingerprint is within MAX_DUPLICATE_DISTANCE. It returns an
+// empty string if there is no such article.
+func (idx *Index) Find(canonicalURL string, fingerprint uint64) string {
+	if id, ok := idx.byURL[canonicalURL]; ok && canonicalURL != "" {
+		return id
+	}
+	if fingerprint == 0 {
+		return ""
+	}
+
+	candidates := make([]Entry, 0)
+	for _, e := range idx.entries {
+		if Distance(e.Fingerprint, fingerprint) <= MAX_DUPLICATE_DISTANCE {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return Distance(candidates[i].Fingerprint, fingerprint) < Distance(candidates[j].Fingerprint, fingerprint)
+	})
+	return candidates[0].ClusterID
+}
@@ -1,35 +1,211 @@
 package email
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"net/smtp"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
 	"strings"
+	"time"
 )
 
-// Send sends an email using the specified SMTP server and authentication.
-func Send(to []string, from, subject, body, smarthost, authIdentity, authUsername, authPassword string) error {
-	// TODO: why are we ignoring the port?
-	host := smarthost
-	if strings.Contains(host, ":") {
-		host = strings.Split(host, ":")[0]
+// Message is an email with both a plain text and an HTML body.
+type Message struct {
+	From    string
+	To      []string
+	Cc      []string
+	Bcc     []string
+	Subject string
+	Text    string
+	HTML    string
+
+	// ListUnsubscribe is a mailto: or https: URL placed in the List-Unsubscribe header. A
+	// bare email address is treated as a mailto: URL. Empty means no header.
+	ListUnsubscribe string
+}
+
+// Recipients returns every recipient of the message: To, then Cc, then Bcc.
+func (m Message) Recipients() []string {
+	recipients := make([]string, 0, len(m.To)+len(m.Cc)+len(m.Bcc))
+	recipients = append(recipients, m.To...)
+	recipients = append(recipients, m.Cc...)
+	return append(recipients, m.Bcc...)
+}
+
+// parseAddresses parses a list of addresses, which may include display names.
+func parseAddresses(addrs []string) ([]*mail.Address, error) {
+	parsed := make([]*mail.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %q: %w", addr, err)
+		}
+		parsed = append(parsed, a)
 	}
+	return parsed, nil
+}
 
-	auth := smtp.PlainAuth(authIdentity, authUsername, authPassword, host)
+// joinAddresses formats a list of addresses for use in a header.
+func joinAddresses(addrs []*mail.Address) string {
+	formatted := make([]string, len(addrs))
+	for i, a := range addrs {
+		formatted[i] = a.String()
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// messageID generates a unique Message-ID in the domain of the sender.
+func messageID(from *mail.Address) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+
+	random := make([]byte, 12)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
+
+// unsubscribeHeader formats the List-Unsubscribe header value.
+func unsubscribeHeader(target string) string {
+	if !strings.Contains(target, ":") && strings.Contains(target, "@") {
+		target = "mailto:" + target
+	}
+	return "<" + target + ">"
+}
+
+// writePart writes one quoted-printable encoded part of a multipart message. Quoted-printable
+// keeps lines under the SMTP line length limit, which long HTML lines would otherwise exceed.
+func writePart(w *multipart.Writer, contentType, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// Bytes builds the RFC 5322 message, with a multipart/alternative body holding the text
+// part followed by the HTML part.
+func (m Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", m.From, err)
+	}
+	to, err := parseAddresses(m.To)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := parseAddresses(m.Cc)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := writePart(mw, "text/plain; charset=\"UTF-8\"", m.Text); err != nil {
+		return nil, fmt.Errorf("failed to write text part: %w", err)
+	}
+	if err := writePart(mw, "text/html; charset=\"UTF-8\"", m.HTML); err != nil {
+		return nil, fmt.Errorf("failed to write html part: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
 
 	// Build the message, headers first then body.
 	var msg []byte
-	msg = fmt.Appendf(msg, "To: %s\r\n", strings.Join(to, ","))
-	msg = fmt.Appendf(msg, "Subject: %s\r\n", subject)
+	msg = fmt.Appendf(msg, "From: %s\r\n", from.String())
+	if len(to) > 0 {
+		msg = fmt.Appendf(msg, "To: %s\r\n", joinAddresses(to))
+	} else {
+		// Every recipient is Bcc; RFC 5322 suggests an empty group so that To is not missing.
+		msg = fmt.Appendf(msg, "To: undisclosed-recipients:;\r\n")
+	}
+	if len(cc) > 0 {
+		msg = fmt.Appendf(msg, "Cc: %s\r\n", joinAddresses(cc))
+	}
+	msg = fmt.Appendf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", m.Subject))
+	msg = fmt.Appendf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg = fmt.Appendf(msg, "Message-ID: %s\r\n", messageID(from))
+	if m.ListUnsubscribe != "" {
+		msg = fmt.Appendf(msg, "List-Unsubscribe: %s\r\n", unsubscribeHeader(m.ListUnsubscribe))
+	}
 	msg = fmt.Appendf(msg, "MIME-Version: 1.0\r\n")
-	msg = fmt.Appendf(msg, "Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	msg = fmt.Appendf(msg, "Content-Type: multipart/alternative; boundary=\"%s\"\r\n", mw.Boundary())
 	msg = fmt.Appendf(msg, "\r\n")
-	msg = fmt.Appendf(msg, "%s\r\n", body)
+	msg = append(msg, body.Bytes()...)
+
+	return msg, nil
+}
+
+// Send sends an email using the specified SMTP server, transport security and authentication.
+func Send(cfg Config, m Message) error {
+	msg, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	// The envelope needs bare addresses, without any display names. Bcc recipients are
+	// only in the envelope, never in the headers.
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", m.From, err)
+	}
+	all, err := parseAddresses(m.Recipients())
+	if err != nil {
+		return err
+	}
+	if len(all) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	recipients := make([]string, len(all))
+	for i, a := range all {
+		recipients[i] = a.Address
+	}
 
 	// Connect to the server, authenticate, and send the email.
-	err := smtp.SendMail(smarthost, auth, from, to, msg)
+	client, err := cfg.connect()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("failed to send email: MAIL FROM: %w", err)
+	}
+	for _, rcpt := range recipients {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("failed to send email: RCPT TO %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
 	if err != nil {
+		return fmt.Errorf("failed to send email: DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
+	// The server has accepted the message, so it has been sent even if QUIT fails.
+	if err := client.Quit(); err != nil {
+		log.Printf("Error closing connection to %s after sending email: %v", cfg.Smarthost, err)
+	}
 	return nil
 }
@@ -0,0 +1,212 @@
+package email
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestMessageBytesHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		headers map[string]string
+	}{
+		{
+			"to and cc",
+			Message{From: "Scraper <scraper@example.com>", To: []string{"a@example.com", "B <b@example.com>"}, Cc: []string{"c@example.com"}, Subject: "Digest"},
+			map[string]string{
+				"From":             `"Scraper" <scraper@example.com>`,
+				"To":               "<a@example.com>, \"B\" <b@example.com>",
+				"Cc":               "<c@example.com>",
+				"Subject":          "Digest",
+				"List-Unsubscribe": "",
+				"MIME-Version":     "1.0",
+			},
+		},
+		{
+			"bcc is never in the headers",
+			Message{From: "scraper@example.com", To: []string{"a@example.com"}, Bcc: []string{"secret@example.com"}, Subject: "Digest"},
+			map[string]string{"To": "<a@example.com>", "Cc": "", "Bcc": ""},
+		},
+		{
+			"only bcc recipients",
+			Message{From: "scraper@example.com", Bcc: []string{"secret@example.com"}, Subject: "Digest"},
+			map[string]string{"To": "undisclosed-recipients:;", "Bcc": ""},
+		},
+		{
+			"subject is encoded",
+			Message{From: "scraper@example.com", To: []string{"a@example.com"}, Subject: "Neuigkeiten für dich"},
+			map[string]string{"Subject": "Neuigkeiten für dich"},
+		},
+		{
+			"unsubscribe address becomes a mailto URL",
+			Message{From: "scraper@example.com", To: []string{"a@example.com"}, ListUnsubscribe: "leave@example.com"},
+			map[string]string{"List-Unsubscribe": "<mailto:leave@example.com>"},
+		},
+		{
+			"unsubscribe URL is kept",
+			Message{From: "scraper@example.com", To: []string{"a@example.com"}, ListUnsubscribe: "https://example.com/unsubscribe"},
+			map[string]string{"List-Unsubscribe": "<https://example.com/unsubscribe>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.msg.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes(): %v", err)
+			}
+			if strings.Contains(string(data), "secret@example.com") {
+				t.Errorf("Bytes() contains a Bcc address:\n%s", data)
+			}
+			parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			dec := new(mime.WordDecoder)
+			for name, want := range tt.headers {
+				got, err := dec.DecodeHeader(parsed.Header.Get(name))
+				if err != nil {
+					t.Fatalf("decoding %s: %v", name, err)
+				}
+				if got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if _, err := parsed.Header.Date(); err != nil {
+				t.Errorf("Date: %v", err)
+			}
+			if id := parsed.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+				t.Errorf("Message-ID = %q, want one in the sender's domain", id)
+			}
+		})
+	}
+}
+
+func TestMessageBytesBody(t *testing.T) {
+	long := strings.Repeat("<p>Z80</p>", 200)
+	msg := Message{From: "scraper@example.com", To: []string{"a@example.com"}, Text: "Plain text", HTML: long}
+	data, err := msg.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes(): %v", err)
+	}
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("line of %d characters, longer than SMTP allows", len(line))
+		}
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", parsed.Header.Get("Content-Type"))
+	}
+
+	want := []struct{ contentType, body string }{
+		{"text/plain", "Plain text"},
+		{"text/html", long},
+	}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, w := range want {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			t.Fatalf("reading %s part: %v", w.contentType, err)
+		}
+		if got, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); got != w.contentType {
+			t.Errorf("part Content-Type = %q, want %q", got, w.contentType)
+		}
+		if got := part.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+			t.Errorf("%s Content-Transfer-Encoding = %q, want quoted-printable", w.contentType, got)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("decoding %s part: %v", w.contentType, err)
+		}
+		if string(body) != w.body {
+			t.Errorf("%s part = %q, want %q", w.contentType, body, w.body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("more than two parts: %v", err)
+	}
+}
+
+func TestMessageBytesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"bad from", Message{From: "not an address", To: []string{"a@example.com"}}},
+		{"bad to", Message{From: "scraper@example.com", To: []string{"a@"}}},
+		{"bad cc", Message{From: "scraper@example.com", To: []string{"a@example.com"}, Cc: []string{"<c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.msg.Bytes(); err == nil {
+				t.Errorf("Bytes() succeeded, want an error")
+			}
+		})
+	}
+}
+
+// smtpServer accepts one connection on a local port and answers it like a mail server,
+// but hangs up instead of answering QUIT. It returns the server's address.
+func smtpServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					if line, err = r.ReadString('\n'); err != nil || line == ".\r\n" {
+						break
+					}
+				}
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSendIgnoresQuitError(t *testing.T) {
+	cfg := Config{Smarthost: smtpServer(t), Security: SECURITY_NONE}
+	msg := Message{From: "scraper@example.com", To: []string{"a@example.com"}, Subject: "Digest", Text: "Hello", HTML: "<p>Hello</p>"}
+	if err := Send(cfg, msg); err != nil {
+		t.Errorf("Send() = %v, want the accepted message to count as sent", err)
+	}
+}
@@ -0,0 +1,218 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Transport security modes.
+const (
+	// SECURITY_STARTTLS upgrades the connection with STARTTLS if the server offers it.
+	SECURITY_STARTTLS = "starttls"
+	// SECURITY_STARTTLS_REQUIRED fails unless the connection can be upgraded with STARTTLS.
+	SECURITY_STARTTLS_REQUIRED = "starttls-required"
+	// SECURITY_TLS connects with TLS from the start ("implicit TLS", usually port 465).
+	SECURITY_TLS = "tls"
+	// SECURITY_NONE never uses TLS.
+	SECURITY_NONE = "none"
+)
+
+// Authentication mechanisms.
+const (
+	// AUTH_AUTO uses PLAIN if a username is configured, and no authentication otherwise.
+	AUTH_AUTO     = ""
+	AUTH_NONE     = "none"
+	AUTH_PLAIN    = "plain"
+	AUTH_LOGIN    = "login"
+	AUTH_CRAM_MD5 = "cram-md5"
+)
+
+// DEFAULT_TIMEOUT bounds how long connecting to and talking with the server may take.
+const DEFAULT_TIMEOUT = 30 * time.Second
+
+// Config describes how to reach the SMTP server.
+type Config struct {
+	// Smarthost is the server as host:port. The port defaults to 465 with SECURITY_TLS,
+	// and 25 otherwise.
+	Smarthost string
+	Security  string
+	Auth      string
+	Identity  string
+	Username  string
+	Password  string
+
+	// CAFile is a PEM file of certificate authorities to trust instead of the system ones.
+	CAFile  string
+	Timeout time.Duration
+}
+
+// hostPort splits the smarthost, applying the default port if there is none.
+func (cfg Config) hostPort() (string, string) {
+	host, port, err := net.SplitHostPort(cfg.Smarthost)
+	if err != nil {
+		host = cfg.Smarthost
+		port = "25"
+		if cfg.Security == SECURITY_TLS {
+			port = "465"
+		}
+	}
+	return host, port
+}
+
+// tlsConfig returns the TLS configuration for the server, trusting CAFile if configured.
+func (cfg Config) tlsConfig(host string) (*tls.Config, error) {
+	tlsCfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file %s: %w", cfg.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return tlsCfg, nil
+}
+
+// auth returns the smtp.Auth for the configured mechanism, or nil for no authentication.
+func (cfg Config) auth(host string) (smtp.Auth, error) {
+	mechanism := strings.ToLower(cfg.Auth)
+	if mechanism == AUTH_AUTO {
+		mechanism = AUTH_NONE
+		if cfg.Username != "" {
+			mechanism = AUTH_PLAIN
+		}
+	}
+
+	switch mechanism {
+	case AUTH_NONE:
+		return nil, nil
+	case AUTH_PLAIN:
+		return smtp.PlainAuth(cfg.Identity, cfg.Username, cfg.Password, host), nil
+	case AUTH_LOGIN:
+		return &loginAuth{username: cfg.Username, password: cfg.Password, host: host}, nil
+	case AUTH_CRAM_MD5:
+		return smtp.CRAMMD5Auth(cfg.Username, cfg.Password), nil
+	default:
+		return nil, fmt.Errorf("unknown email auth mechanism %q", cfg.Auth)
+	}
+}
+
+// connect dials the server, secures the connection and authenticates, as configured.
+func (cfg Config) connect() (*smtp.Client, error) {
+	host, port := cfg.hostPort()
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+
+	security := strings.ToLower(cfg.Security)
+	if security == "" {
+		security = SECURITY_STARTTLS
+	}
+
+	tlsCfg, err := cfg.tlsConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := cfg.auth(host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	addr := net.JoinHostPort(host, port)
+
+	var conn net.Conn
+	switch security {
+	case SECURITY_TLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsCfg)
+	case SECURITY_STARTTLS, SECURITY_STARTTLS_REQUIRED, SECURITY_NONE:
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return nil, fmt.Errorf("unknown email security mode %q", cfg.Security)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The deadline covers the whole conversation, so a stalled server can't hang us.
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if security == SECURITY_STARTTLS || security == SECURITY_STARTTLS_REQUIRED {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsCfg); err != nil {
+				_ = client.Close()
+				return nil, fmt.Errorf("STARTTLS: %w", err)
+			}
+		} else if security == SECURITY_STARTTLS_REQUIRED {
+			_ = client.Close()
+			return nil, errors.New("server does not support STARTTLS")
+		}
+	}
+
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			_ = client.Close()
+			return nil, errors.New("server does not support authentication; set the auth mechanism to none")
+		}
+		if err := client.Auth(auth); err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// loginAuth implements the LOGIN authentication mechanism, which net/smtp does not provide.
+// Like smtp.PlainAuth, it refuses to send credentials over an unencrypted connection to
+// anything other than localhost.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && a.host != "localhost" && a.host != "127.0.0.1" && a.host != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "user"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "pass"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
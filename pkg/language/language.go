@@ -0,0 +1,107 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MIN_STOPWORD_HITS is the minimum number of stopwords that must be found before a
+// Latin-script text is attributed to a language. Short titles often have none.
+const MIN_STOPWORD_HITS = 2
+
+// names maps the ISO 639-1 codes that Detect may return to English language names.
+var names = map[string]string{
+	"en": "English",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"it": "Italian",
+	"nl": "Dutch",
+	"ja": "Japanese",
+	"zh": "Chinese",
+	"ko": "Korean",
+	"ru": "Russian",
+}
+
+// stopwords are common short words that are a good signal of a Latin-script language.
+// Words shared between languages (e.g. "in", or "die", which is German, Dutch and English) are
+// left out, except that "de", the most common Spanish word, counts as Spanish.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "that", "for", "with", "this", "on", "are", "was", "it", "you", "from", "by", "have"},
+	"de": {"der", "das", "und", "ist", "nicht", "mit", "ein", "eine", "auf", "für", "von", "sich", "dem", "auch", "wird"},
+	"fr": {"le", "la", "les", "et", "est", "une", "des", "pour", "dans", "qui", "sur", "pas", "avec", "du", "sont"},
+	"es": {"de", "el", "los", "las", "y", "es", "por", "para", "que", "del", "como", "más", "pero", "se", "muy"},
+	"it": {"il", "gli", "e", "è", "per", "che", "della", "sono", "non", "di", "anche", "questo", "nel", "degli"},
+	"nl": {"het", "een", "en", "van", "niet", "met", "voor", "op", "zijn", "ook", "dat", "wordt", "naar"},
+}
+
+// Name returns the English name of the language with the given ISO 639-1 code, or the
+// code itself if it is not known.
+func Name(code string) string {
+	if name, ok := names[code]; ok {
+		return name
+	}
+	return code
+}
+
+// Detect makes a best-effort guess at the language of the text and returns its ISO 639-1
+// code, or an empty string if it cannot tell. Non-Latin scripts are identified by their
+// characters, Latin-script languages by counting common stopwords.
+func Detect(text string) string {
+	var letters, kana, han, hangul, cyrillic int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Japanese mixes kana with kanji, so any noticeable amount of kana is decisive.
+	switch {
+	case kana*10 >= letters:
+		return "ja"
+	case hangul*3 >= letters:
+		return "ko"
+	case han*3 >= letters:
+		return "zh"
+	case cyrillic*3 >= letters:
+		return "ru"
+	}
+
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for code, words := range stopwords {
+			for _, w := range words {
+				if w == word {
+					counts[code]++
+				}
+			}
+		}
+	}
+
+	best, bestCount := "", 0
+	for code, count := range counts {
+		if count > bestCount || (count == bestCount && code < best) {
+			best, bestCount = code, count
+		}
+	}
+	if bestCount < MIN_STOPWORD_HITS {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// DISCORD_MAX_LENGTH is the longest message Discord accepts, less room for the header and
+// the count of articles left out.
+const DISCORD_MAX_LENGTH = 1800
+
+// discordEscaper escapes the characters that Discord's markdown treats as markup in titles.
+var discordEscaper = strings.NewReplacer("[", "\\[", "]", "\\]", "*", "\\*", "_", "\\_", "`", "\\`")
+
+// discordLinkEscaper percent-encodes the characters that would end the URL in Discord's
+// [text](<url>) syntax. The encoded URL still leads to the same page.
+var discordLinkEscaper = strings.NewReplacer("<", "%3C", ">", "%3E", "(", "%28", ")", "%29", " ", "%20")
+
+// discord posts to a Discord channel webhook.
+type discord struct {
+	url    string
+	client *http.Client
+}
+
+func (d *discord) Name() string {
+	return TYPE_DISCORD
+}
+
+func (d *discord) Notify(ctx context.Context, rep *report.Report) error {
+	// Links in angle brackets do not get embedded previews, which would swamp the channel.
+	lines, more := listArticles(rep, DISCORD_MAX_LENGTH, func(art storage.Article) string {
+		return "• [" + discordEscaper.Replace(title(art)) + "](<" + discordLinkEscaper.Replace(art.Link) + ">) " + details(rep, art)
+	})
+
+	content := "**" + rep.Title + "**\n" + strings.Join(lines, "\n")
+	if more > 0 {
+		content += "\n" + moreLine(more)
+	}
+
+	body, err := json.Marshal(map[string]any{"content": content, "username": "ai-rss-scraper"})
+	if err != nil {
+		return err
+	}
+	return send(ctx, d.client, http.MethodPost, d.url, nil, body)
+}
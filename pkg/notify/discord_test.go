@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"testing"
+)
+
+func TestDiscordLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"https://example.com/a?x=1&y=2", "https://example.com/a?x=1&y=2"},
+		{"https://example.com/<a>", "https://example.com/%3Ca%3E"},
+		{"https://en.wikipedia.org/wiki/Z80_(disambiguation)", "https://en.wikipedia.org/wiki/Z80_%28disambiguation%29"},
+		{"https://example.com/a b", "https://example.com/a%20b"},
+		{"https://example.com/a|b", "https://example.com/a|b"},
+	}
+	for _, tt := range tests {
+		if got := discordLinkEscaper.Replace(tt.link); got != tt.want {
+			t.Errorf("discordLinkEscaper.Replace(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// matrix sends a message to a Matrix room through the client-server API.
+type matrix struct {
+	homeserver string
+	room       string
+	token      string
+	client     *http.Client
+}
+
+func (m *matrix) Name() string {
+	return TYPE_MATRIX + ":" + m.room
+}
+
+func (m *matrix) Notify(ctx context.Context, rep *report.Report) error {
+	plain, more := listArticles(rep, 0, func(art storage.Article) string {
+		return "- " + title(art) + " " + details(rep, art) + ": " + art.Link
+	})
+	formatted, _ := listArticles(rep, 0, func(art storage.Article) string {
+		return `<li><a href="` + html.EscapeString(art.Link) + `">` + html.EscapeString(title(art)) + "</a> " + html.EscapeString(details(rep, art)) + "</li>"
+	})
+
+	text := rep.Title + "\n" + strings.Join(plain, "\n")
+	formattedText := "<b>" + html.EscapeString(rep.Title) + "</b><ul>" + strings.Join(formatted, "") + "</ul>"
+	if more > 0 {
+		text += "\n" + moreLine(more)
+		formattedText += "<p>" + moreLine(more) + "</p>"
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"msgtype":        "m.text",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formattedText,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The transaction ID is derived from the report, so a retry after a lost response does
+	// not post the message twice.
+	txnID := fmt.Sprintf("ai-rss-scraper-%d", rep.GeneratedAt.UnixNano())
+	endpoint := strings.TrimRight(m.homeserver, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(m.room) +
+		"/send/m.room.message/" + txnID
+	return send(ctx, m.client, http.MethodPut, endpoint, map[string]string{"Authorization": "Bearer " + m.token}, body)
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// title returns the title to show for an article, preferring the translation.
+func title(art storage.Article) string {
+	if art.TranslatedTitle != "" {
+		return art.TranslatedTitle
+	}
+	return art.Title
+}
+
+// details returns the score, source and story size of an article, for showing after its
+// title.
+func details(rep *report.Report, art storage.Article) string {
+	text := fmt.Sprintf("(%s) %s", art.Score, rep.FeedName(art.FeedURL))
+	if len(art.Related) > 0 {
+		text += fmt.Sprintf(", +%d related", len(art.Related))
+	}
+	return text
+}
+
+// listArticles formats up to MAX_MESSAGE_ARTICLES of the report's articles with the given
+// function, stopping early if the total length would exceed maxLength (0 means no limit).
+// It returns the formatted lines and the number of articles left out.
+func listArticles(rep *report.Report, maxLength int, format func(art storage.Article) string) ([]string, int) {
+	var lines []string
+	length := 0
+	for i, art := range rep.Articles {
+		line := format(art)
+		if i >= MAX_MESSAGE_ARTICLES || (maxLength > 0 && length+len(line) > maxLength) {
+			return lines, len(rep.Articles) - i
+		}
+		lines = append(lines, line)
+		length += len(line) + 1
+	}
+	return lines, 0
+}
+
+// moreLine describes the articles that were left out of a message.
+func moreLine(more int) string {
+	return fmt.Sprintf("…and %d more", more)
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+)
+
+// Notifier types.
+const (
+	TYPE_SLACK   = "slack"
+	TYPE_DISCORD = "discord"
+	TYPE_MATRIX  = "matrix"
+	TYPE_WEBHOOK = "webhook"
+)
+
+// DEFAULT_RETRIES is the default number of times a failed notification is retried.
+const DEFAULT_RETRIES = 3
+
+// DEFAULT_TIMEOUT is the default timeout for each request to a chat service.
+const DEFAULT_TIMEOUT = 30 * time.Second
+
+// RETRY_DELAY is the delay before the first retry. It doubles for every further retry.
+const RETRY_DELAY = 2 * time.Second
+
+// MAX_MESSAGE_ARTICLES is the most articles listed in a chat message. Any more are
+// summarized as a count, since chat services limit the length of messages.
+const MAX_MESSAGE_ARTICLES = 20
+
+// Config configures one notification channel.
+type Config struct {
+	Type string
+
+	// URL is the incoming webhook URL for Slack, Discord and generic webhooks, or the
+	// homeserver URL for Matrix.
+	URL string
+
+	// Room and Token are the Matrix room ID (or alias) and access token.
+	Room  string
+	Token string
+
+	// Template is a text/template for the body of a generic webhook, executed with the
+	// report's JSON shape. Empty means send the JSON report itself.
+	Template string
+
+	// Threshold is the minimum score for an article to be sent to this channel. Articles
+	// below the report's own threshold are never sent, whatever this is.
+	Threshold int
+
+	Timeout time.Duration
+}
+
+// Notifier posts reports to a chat service.
+type Notifier interface {
+	// Name identifies the channel, e.g. "slack" or "matrix:!room:example.org".
+	Name() string
+
+	// Notify makes a single attempt to post the report.
+	Notify(ctx context.Context, rep *report.Report) error
+}
+
+// New creates the notifier described by the configuration.
+func New(cfg Config) (Notifier, error) {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+	client := &http.Client{Timeout: timeout}
+
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("%s notifier has no URL", cfg.Type)
+	}
+
+	switch cfg.Type {
+	case TYPE_SLACK:
+		return &slack{url: cfg.URL, client: client}, nil
+	case TYPE_DISCORD:
+		return &discord{url: cfg.URL, client: client}, nil
+	case TYPE_MATRIX:
+		if cfg.Room == "" || cfg.Token == "" {
+			return nil, fmt.Errorf("matrix notifier needs a room and an access token")
+		}
+		return &matrix{homeserver: cfg.URL, room: cfg.Room, token: cfg.Token, client: client}, nil
+	case TYPE_WEBHOOK:
+		return newWebhook(cfg.URL, cfg.Template, client)
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+}
+
+// Send posts the report, retrying up to the given number of times if the attempt fails
+// in a way that may be temporary: a network error, a server error, or rate limiting.
+func Send(ctx context.Context, n Notifier, rep *report.Report, retries int) error {
+	return retry(ctx, n.Name(), retries, func() error {
+		return n.Notify(ctx, rep)
+	})
+}
+
+// retry calls attempt until it succeeds, fails permanently, or has been retried the given
+// number of times, doubling the delay between attempts. It stops waiting to retry if the
+// context is cancelled.
+func retry(ctx context.Context, name string, retries int, attempt func() error) error {
+	delay := RETRY_DELAY
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		var statusErr *StatusError
+		isStatus := errors.As(err, &statusErr)
+		if i >= retries || (isStatus && !statusErr.Temporary()) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := delay
+		if isStatus && statusErr.RetryAfter > 0 {
+			wait = statusErr.RetryAfter
+		}
+		log.Printf("Error notifying %s (attempt %d of %d), retrying in %v: %v", name, i+1, retries+1, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
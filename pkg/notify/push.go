@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Push service types.
+const (
+	TYPE_NTFY   = "ntfy"
+	TYPE_GOTIFY = "gotify"
+)
+
+// Alert is a push notification about a single must-read article.
+type Alert struct {
+	Title string
+	Score int
+	Link  string
+}
+
+// message returns the body text of the alert.
+func (a Alert) message() string {
+	return fmt.Sprintf("Score %d: %s", a.Score, a.Link)
+}
+
+// Pusher sends alerts to a push notification service.
+type Pusher interface {
+	// Name identifies the service, e.g. "ntfy:alerts".
+	Name() string
+
+	// Push makes a single attempt to send the alert.
+	Push(ctx context.Context, a Alert) error
+}
+
+// NewPusher creates a pusher for the given service type. For ntfy, the URL is the topic URL
+// (e.g. https://ntfy.sh/mytopic) and the token is optional; for Gotify, the URL is the
+// server and the token is an application token.
+func NewPusher(pushType, rawURL, token string, timeout time.Duration) (Pusher, error) {
+	if timeout == 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+	client := &http.Client{Timeout: timeout}
+
+	switch pushType {
+	case TYPE_NTFY:
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("ntfy URL %q must include the topic, e.g. https://ntfy.sh/mytopic", rawURL)
+		}
+		topic := path.Base(u.Path)
+		u.Path = path.Dir(strings.TrimRight(u.Path, "/"))
+		return &ntfy{server: strings.TrimRight(u.String(), "/"), topic: topic, token: token, client: client}, nil
+	case TYPE_GOTIFY:
+		if rawURL == "" || token == "" {
+			return nil, fmt.Errorf("gotify needs a server URL and an application token")
+		}
+		return &gotify{server: strings.TrimRight(rawURL, "/"), token: token, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unknown push service %q", pushType)
+	}
+}
+
+// SendAlert pushes the alert, retrying temporary failures like Send.
+func SendAlert(ctx context.Context, p Pusher, a Alert, retries int) error {
+	return retry(ctx, p.Name(), retries, func() error {
+		return p.Push(ctx, a)
+	})
+}
+
+// ntfy publishes to a topic on an ntfy server, using its JSON publishing API.
+type ntfy struct {
+	server string
+	topic  string
+	token  string
+	client *http.Client
+}
+
+func (n *ntfy) Name() string {
+	return TYPE_NTFY + ":" + n.topic
+}
+
+func (n *ntfy) Push(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(map[string]any{
+		"topic":    n.topic,
+		"title":    a.Title,
+		"message":  a.message(),
+		"click":    a.Link,
+		"priority": 4,
+		"tags":     []string{"star"},
+	})
+	if err != nil {
+		return err
+	}
+
+	var headers map[string]string
+	if n.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + n.token}
+	}
+	return send(ctx, n.client, http.MethodPost, n.server, headers, body)
+}
+
+// gotify sends a message through a Gotify server's application API.
+type gotify struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+func (g *gotify) Name() string {
+	if u, err := url.Parse(g.server); err == nil && u.Host != "" {
+		return TYPE_GOTIFY + ":" + u.Host
+	}
+	return TYPE_GOTIFY
+}
+
+func (g *gotify) Push(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(map[string]any{
+		"title":    a.Title,
+		"message":  a.message(),
+		"priority": 8,
+		"extras": map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": a.Link}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return send(ctx, g.client, http.MethodPost, g.server+"/message", map[string]string{"X-Gotify-Key": g.token}, body)
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// slackEscaper escapes the characters that Slack's mrkdwn treats as markup.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackLinkEscaper percent-encodes the characters that would end a link, or its URL, in
+// Slack's <url|text> syntax. The encoded URL still leads to the same page.
+var slackLinkEscaper = strings.NewReplacer("|", "%7C", "<", "%3C", ">", "%3E", " ", "%20")
+
+// slackLink returns the link, escaped for use in <url|text>.
+func slackLink(link string) string {
+	return slackEscaper.Replace(slackLinkEscaper.Replace(link))
+}
+
+// slack posts to a Slack incoming webhook.
+type slack struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slack) Name() string {
+	return TYPE_SLACK
+}
+
+func (s *slack) Notify(ctx context.Context, rep *report.Report) error {
+	lines, more := listArticles(rep, 0, func(art storage.Article) string {
+		return "• <" + slackLink(art.Link) + "|" + slackEscaper.Replace(title(art)) + "> " + slackEscaper.Replace(details(rep, art))
+	})
+
+	text := "*" + slackEscaper.Replace(rep.Title) + "*\n" + strings.Join(lines, "\n")
+	if more > 0 {
+		text += "\n" + moreLine(more)
+	}
+
+	body, err := json.Marshal(map[string]any{"text": text, "unfurl_links": false})
+	if err != nil {
+		return err
+	}
+	return send(ctx, s.client, http.MethodPost, s.url, nil, body)
+}
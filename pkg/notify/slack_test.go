@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"testing"
+)
+
+func TestSlackLink(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://example.com/a", "https://example.com/a"},
+		{"https://example.com/a?x=1&y=2", "https://example.com/a?x=1&amp;y=2"},
+		{"https://example.com/a|b", "https://example.com/a%7Cb"},
+		{"https://example.com/<a>", "https://example.com/%3Ca%3E"},
+		{"https://example.com/a b", "https://example.com/a%20b"},
+		{"https://example.com/(a)", "https://example.com/(a)"},
+	}
+	for _, tt := range tests {
+		if got := slackLink(tt.link); got != tt.want {
+			t.Errorf("slackLink(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
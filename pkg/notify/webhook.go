@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/report"
+)
+
+// webhook posts to a generic HTTP endpoint. The body is the JSON report, or the output of a
+// template executed with the JSON report's shape.
+type webhook struct {
+	url    string
+	tmpl   *template.Template
+	client *http.Client
+}
+
+// webhookFuncs are the helper functions available to webhook body templates.
+var webhookFuncs = template.FuncMap{
+	// json encodes a value as JSON, so strings can be embedded safely in a JSON body.
+	"json": func(v any) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
+}
+
+func newWebhook(rawURL, body string, client *http.Client) (*webhook, error) {
+	w := &webhook{url: rawURL, client: client}
+	if body != "" {
+		tmpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing webhook template: %w", err)
+		}
+		w.tmpl = tmpl
+	}
+	return w, nil
+}
+
+func (w *webhook) Name() string {
+	if u, err := url.Parse(w.url); err == nil && u.Host != "" {
+		return TYPE_WEBHOOK + ":" + u.Host
+	}
+	return TYPE_WEBHOOK
+}
+
+func (w *webhook) Notify(ctx context.Context, rep *report.Report) error {
+	var body []byte
+	if w.tmpl == nil {
+		content, err := rep.GenerateJSON()
+		if err != nil {
+			return err
+		}
+		body = []byte(content)
+	} else {
+		var buf bytes.Buffer
+		if err := w.tmpl.Execute(&buf, rep.JSON()); err != nil {
+			return fmt.Errorf("error executing webhook template: %w", err)
+		}
+		body = buf.Bytes()
+	}
+	return send(ctx, w.client, http.MethodPost, w.url, nil, body)
+}
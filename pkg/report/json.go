@@ -0,0 +1,149 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// JSON_SCHEMA_VERSION is incremented whenever a field is removed from, or changes meaning
+// in, the JSON report. Adding fields does not change the version.
+const JSON_SCHEMA_VERSION = 1
+
+// JSONReport is the stable, documented shape of a report in JSON format.
+type JSONReport struct {
+	SchemaVersion int           `json:"schema_version"`
+	Title         string        `json:"title"`
+	GeneratedAt   time.Time     `json:"generated_at"`
+	Threshold     int           `json:"threshold"`
+	AgeDays       int           `json:"age_days"`
+	Count         int           `json:"count"`
+	Omitted       int           `json:"omitted"`
+	MoreURL       string        `json:"more_url,omitempty"`
+	Feeds         []JSONFeed    `json:"feeds"`
+	Articles      []JSONArticle `json:"articles"`
+	Sections      []JSONSection `json:"sections"`
+}
+
+// JSONFeed is a feed that contributed articles to the report.
+type JSONFeed struct {
+	URL   string `json:"url"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// JSONArticle is a single article in the report. Score is null if the article was not
+// scored, or the model's score was not a number.
+type JSONArticle struct {
+	GUID              string        `json:"guid"`
+	Title             string        `json:"title"`
+	Link              string        `json:"link"`
+	Description       string        `json:"description"`
+	PublishedDate     time.Time     `json:"published_date"`
+	FeedURL           string        `json:"feed_url"`
+	FeedName          string        `json:"feed_name"`
+	Score             *int          `json:"score"`
+	Analysis          string        `json:"analysis"`
+	Model             string        `json:"model"`
+	Tags              []string      `json:"tags"`
+	Language          string        `json:"language"`
+	TranslatedTitle   string        `json:"translated_title"`
+	TranslatedSummary string        `json:"translated_summary"`
+	Duplicates        []JSONLink    `json:"duplicates"`
+	Related           []JSONArticle `json:"related"`
+}
+
+// JSONLink is another copy of an article, found in a different feed.
+type JSONLink struct {
+	GUID    string `json:"guid"`
+	Title   string `json:"title"`
+	Link    string `json:"link"`
+	FeedURL string `json:"feed_url"`
+}
+
+// JSONSection is a named group of articles, referring to them by GUID.
+type JSONSection struct {
+	Name  string   `json:"name"`
+	GUIDs []string `json:"guids"`
+}
+
+// toJSONArticle converts an article, including its duplicates and related articles.
+func (r *Report) toJSONArticle(art storage.Article) JSONArticle {
+	ja := JSONArticle{
+		GUID:              art.GUID,
+		Title:             art.Title,
+		Link:              art.Link,
+		Description:       art.Description,
+		PublishedDate:     art.PublishedDate,
+		FeedURL:           art.FeedURL,
+		FeedName:          r.FeedName(art.FeedURL),
+		Analysis:          art.Analysis,
+		Model:             art.Model,
+		Tags:              art.Tags,
+		Language:          art.Language,
+		TranslatedTitle:   art.TranslatedTitle,
+		TranslatedSummary: art.TranslatedSummary,
+		Duplicates:        []JSONLink{},
+		Related:           []JSONArticle{},
+	}
+	if ja.Tags == nil {
+		ja.Tags = []string{}
+	}
+	if score, err := strconv.Atoi(art.Score); err == nil {
+		ja.Score = &score
+	}
+	for _, dup := range art.Duplicates {
+		ja.Duplicates = append(ja.Duplicates, JSONLink{GUID: dup.GUID, Title: dup.Title, Link: dup.Link, FeedURL: dup.FeedURL})
+	}
+	for _, related := range art.Related {
+		ja.Related = append(ja.Related, r.toJSONArticle(related))
+	}
+	return ja
+}
+
+// JSON returns the report in its JSON shape.
+func (r *Report) JSON() JSONReport {
+	jr := JSONReport{
+		SchemaVersion: JSON_SCHEMA_VERSION,
+		Title:         r.Title,
+		GeneratedAt:   r.GeneratedAt,
+		Threshold:     r.Threshold,
+		AgeDays:       r.AgeDays,
+		Count:         r.Count(),
+		Omitted:       r.Omitted,
+		MoreURL:       r.MoreURL,
+		Feeds:         []JSONFeed{},
+		Articles:      []JSONArticle{},
+		Sections:      []JSONSection{},
+	}
+	for _, feed := range r.Feeds() {
+		jr.Feeds = append(jr.Feeds, JSONFeed{URL: feed.URL, Name: feed.Name, Count: feed.Count})
+	}
+	for _, art := range r.Articles {
+		jr.Articles = append(jr.Articles, r.toJSONArticle(art))
+	}
+	for _, section := range r.Sections {
+		js := JSONSection{Name: section.Name, GUIDs: []string{}}
+		for _, art := range section.Articles {
+			js.GUIDs = append(js.GUIDs, art.GUID)
+		}
+		jr.Sections = append(jr.Sections, js)
+	}
+	return jr
+}
+
+// GenerateJSON renders the report as indented JSON.
+func (r *Report) GenerateJSON() (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r.JSON()); err != nil {
+		return "", fmt.Errorf("error encoding report as json: %w", err)
+	}
+	return buf.String(), nil
+}
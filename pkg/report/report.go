@@ -3,103 +3,321 @@ package report
 import (
 	"bytes"
 	"fmt"
-	"html/template"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/scottmbaker/ai-rss-scraper/pkg/email"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
 	"github.com/scottmbaker/ai-rss-scraper/pkg/utils"
 )
 
+// Report formats.
+const (
+	FORMAT_HTML     = "html"
+	FORMAT_MARKDOWN = "markdown"
+	FORMAT_TEXT     = "text"
+	FORMAT_JSON     = "json"
+	FORMAT_ATOM     = "atom"
+	FORMAT_RSS      = "rss"
+	FORMAT_JSONFEED = "jsonfeed"
+)
+
+// FORMATS lists the supported report formats.
+var FORMATS = []string{FORMAT_HTML, FORMAT_MARKDOWN, FORMAT_TEXT, FORMAT_JSON, FORMAT_ATOM, FORMAT_RSS, FORMAT_JSONFEED}
+
+// Sort orders for the articles in a report.
+const (
+	SORT_SCORE = "score"
+	SORT_DATE  = "date"
+	SORT_FEED  = "feed"
+)
+
+// SORTS lists the supported sort orders.
+var SORTS = []string{SORT_SCORE, SORT_DATE, SORT_FEED}
+
+// Ways of grouping a report into sections.
+const (
+	GROUP_FEED = "feed"
+	GROUP_TAG  = "tag"
+	GROUP_DAY  = "day"
+)
+
+// GROUPS lists the supported groupings.
+var GROUPS = []string{GROUP_FEED, GROUP_TAG, GROUP_DAY}
+
+// UNTAGGED is the name of the section holding articles without any tags.
+const UNTAGGED = "untagged"
+
 // Report holds the data to be rendered in the template.
 type Report struct {
 	Title    string
 	Articles []storage.Article
+	Sections []Section
+
+	// GeneratedAt, Threshold and AgeDays describe how the articles were selected.
+	GeneratedAt time.Time
+	Threshold   int
+	AgeDays     int
+
+	// FeedNames maps feed URLs to their titles, for display.
+	FeedNames map[string]string
+
+	// SiteURL is the base URL of the web server publishing the report, used for the self
+	// links of feed formats. Empty if the report is not published on the web.
+	SiteURL string
+
+	// Omitted is the number of articles left out of the report to keep it short, and MoreURL
+	// the page of the web interface where they can be found. MoreURL may be empty.
+	Omitted int
+	MoreURL string
+
+	// TemplatePath is a template file, or a directory containing report.html, used in place
+	// of the embedded default template. Empty means use the default.
+	TemplatePath string
+}
+
+// Feed is a feed that contributed articles to a report.
+type Feed struct {
+	URL   string
+	Name  string
+	Count int
+}
+
+// Section is a named group of articles within a report.
+type Section struct {
+	Name     string
+	Articles []storage.Article
+}
+
+// Select returns the articles that belong in a report: those scoring at least the threshold
+// and carrying the tag, if one is given. Duplicates are left out, since they are shown
+// alongside their cluster's representative. Articles without a numeric score count as 0.
+func Select(articles []storage.Article, threshold int, tag string) []storage.Article {
+	var selected []storage.Article
+	for _, art := range articles {
+		if art.IsDuplicate() {
+			continue
+		}
+		if scoreOf(art) >= threshold && (tag == "" || slices.Contains(art.Tags, tag)) {
+			selected = append(selected, art)
+		}
+	}
+	return selected
+}
+
+// Limit returns at most max articles, keeping those with the highest scores and leaving
+// them in their original order, and the number of articles left out. A max of 0 or less
+// means no limit.
+func Limit(articles []storage.Article, max int) ([]storage.Article, int) {
+	if max <= 0 || len(articles) <= max {
+		return articles, 0
+	}
+
+	ranked := slices.Clone(articles)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scoreOf(ranked[i]) > scoreOf(ranked[j])
+	})
+	keep := make(map[string]bool, max)
+	for _, art := range ranked[:max] {
+		keep[art.GUID] = true
+	}
+
+	kept := make([]storage.Article, 0, max)
+	for _, art := range articles {
+		if keep[art.GUID] {
+			kept = append(kept, art)
+		}
+	}
+	return kept, len(articles) - len(kept)
+}
+
+// scoreOf returns the article's score, or 0 if it has no numeric score.
+func scoreOf(art storage.Article) int {
+	score, err := strconv.Atoi(art.Score)
+	if err != nil {
+		return 0
+	}
+	return score
 }
 
 // NewReport creates a new Report instance.
 func NewReport(title string, articles []storage.Article) *Report {
 	return &Report{
-		Title:    title,
-		Articles: articles,
-	}
-}
-
-// The template is hardcoded here as it eliminates the need to include a separate file.
-// TODO: Make it configurable.
-const reportTemplate = `
-<!DOCTYPE html>
-<html>
-<head>
-	<meta charset="UTF-8">
-	<title>{{.Title}}</title>
-	<style>
-		body { font-family: sans-serif; max-width: 900px; margin: 2em auto; padding: 0 1em; background: #f4f4f4; color: #333; }
-		h1 { text-align: center; color: #444; }
-		.article { background: #fff; padding: 1.5em; margin-bottom: 1.5em; border-radius: 8px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }
-		.header { display: flex; justify-content: space-between; align-items: baseline; border-bottom: 2px solid #eee; padding-bottom: 0.5em; margin-bottom: 1em; }
-		.title { font-size: 1.4em; font-weight: bold; }
-		.title a { text-decoration: none; color: #2c3e50; }
-		.title a:hover { color: #3498db; }
-		.link { font-size: 0.85em; color: #3498db; margin-top: 0.2em; }
-		.link a { text-decoration: none; color: #3498db; }
-		.link a:hover { text-decoration: underline; }
-		.meta { font-size: 0.85em; color: #888; text-align: right; }
-		.score { font-weight: bold; color: #e67e22; font-size: 1.1em; }
-		.analysis { font-style: italic; background: #f9f9f9; padding: 1em; border-left: 4px solid #3498db; margin: 1em 0; white-space: pre-wrap; }
-		.description { line-height: 1.6; }
-		.content { display: none; margin-top: 1em; padding-top: 1em; border-top: 1px dashed #ccc; font-size: 0.9em; color: #555; }
-		.toggle-content { cursor: pointer; color: #3498db; font-size: 0.9em; user-select: none; }
-		.toggle-content:hover { text-decoration: underline; }
-	</style>
-	<script>
-		function toggleContent(id) {
-			var el = document.getElementById('content-' + id);
-			if (el.style.display === 'block') {
-				el.style.display = 'none';
-			} else {
-				el.style.display = 'block';
+		Title:       title,
+		Articles:    articles,
+		GeneratedAt: time.Now(),
+		FeedNames:   map[string]string{},
+	}
+}
+
+// Count returns the number of articles in the report.
+func (r *Report) Count() int {
+	return len(r.Articles)
+}
+
+// FeedName returns the display name of the feed with the given URL.
+func (r *Report) FeedName(feedURL string) string {
+	if name := r.FeedNames[feedURL]; name != "" {
+		return name
+	}
+	return hostname(feedURL)
+}
+
+// Feeds returns the feeds that contributed articles to the report, most articles first.
+func (r *Report) Feeds() []Feed {
+	counts := make(map[string]int)
+	for _, art := range r.Articles {
+		counts[art.FeedURL]++
+	}
+
+	feeds := make([]Feed, 0, len(counts))
+	for feedURL, count := range counts {
+		feeds = append(feeds, Feed{URL: feedURL, Name: r.FeedName(feedURL), Count: count})
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		if feeds[i].Count != feeds[j].Count {
+			return feeds[i].Count > feeds[j].Count
+		}
+		return feeds[i].Name < feeds[j].Name
+	})
+	return feeds
+}
+
+// GroupByTag splits the report into one section per tag.
+func (r *Report) GroupByTag() {
+	r.Sections = GroupByTag(r.Articles)
+}
+
+// Sort orders the report's articles: by score, highest first; by publish date, newest
+// first; or by feed name, then score. Sections are not changed, so sort before grouping.
+func (r *Report) Sort(order string) {
+	switch order {
+	case SORT_SCORE:
+		sort.SliceStable(r.Articles, func(i, j int) bool {
+			return scoreOf(r.Articles[i]) > scoreOf(r.Articles[j])
+		})
+	case SORT_DATE:
+		sort.SliceStable(r.Articles, func(i, j int) bool {
+			return r.Articles[i].PublishedDate.After(r.Articles[j].PublishedDate)
+		})
+	case SORT_FEED:
+		sort.SliceStable(r.Articles, func(i, j int) bool {
+			fi, fj := r.FeedName(r.Articles[i].FeedURL), r.FeedName(r.Articles[j].FeedURL)
+			if fi != fj {
+				return fi < fj
 			}
+			return scoreOf(r.Articles[i]) > scoreOf(r.Articles[j])
+		})
+	}
+}
+
+// Group splits the report into sections by feed, tag or publish day. Articles keep their
+// order within each section. An empty grouping removes any sections.
+func (r *Report) Group(by string) {
+	switch by {
+	case GROUP_FEED:
+		r.Sections = r.GroupByFeed(r.Articles)
+	case GROUP_TAG:
+		r.Sections = GroupByTag(r.Articles)
+	case GROUP_DAY:
+		r.Sections = GroupByDay(r.Articles)
+	default:
+		r.Sections = nil
+	}
+}
+
+// GroupByFeed groups articles into one section per feed, named after the feed, largest
+// sections first.
+func (r *Report) GroupByFeed(articles []storage.Article) []Section {
+	byFeed := make(map[string][]storage.Article)
+	for _, art := range articles {
+		byFeed[art.FeedURL] = append(byFeed[art.FeedURL], art)
+	}
+
+	sections := make([]Section, 0, len(byFeed))
+	for feedURL, arts := range byFeed {
+		sections = append(sections, Section{Name: r.FeedName(feedURL), Articles: arts})
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		if len(sections[i].Articles) != len(sections[j].Articles) {
+			return len(sections[i].Articles) > len(sections[j].Articles)
 		}
-	</script>
-</head>
-<body>
-	<h1>{{.Title}}</h1>
-	{{range .Articles}}
-	<div class="article">
-		<div class="header">
-			<div>
-				<div class="title"><a href="{{.Link}}" target="_blank">{{.Title}}</a></div>
-				<div class="link"><a href="{{.Link}}" target="_blank">{{.Link}}</a></div>
-			</div>
-			<div class="meta">
-				<span class="score">Score: {{.Score}}</span><br>
-				{{.PublishedDate.Format "2006-01-02 15:04"}}<br>
-				<span style="font-size:0.8em">{{.Model}}</span>
-			</div>
-		</div>
-		{{if .Analysis}}
-		<div class="analysis"><strong>Analysis:</strong><br>{{.Analysis}}</div>
-		{{end}}
-		<div class="description">{{.Description}}</div>
-		{{if .Content}}
-		<div class="toggle-content" onclick="toggleContent('{{.GUID}}')">Show/Hide Full Content</div>
-		<div id="content-{{.GUID}}" class="content">{{.Content}}</div>
-		{{end}}
-	</div>
-	{{else}}
-	<p style="text-align:center">No articles found.</p>
-	{{end}}
-</body>
-</html>
-`
+		return sections[i].Name < sections[j].Name
+	})
+	return sections
+}
 
-// GenerateHTML generates the HTML report string from the given articles.
-func (r *Report) GenerateHTML() (string, error) {
-	tmpl, err := template.New("report").Parse(reportTemplate)
+// GroupByDay groups articles into one section per publish date, most recent first.
+func GroupByDay(articles []storage.Article) []Section {
+	byDay := make(map[string][]storage.Article)
+	for _, art := range articles {
+		day := art.PublishedDate.Format("2006-01-02")
+		byDay[day] = append(byDay[day], art)
+	}
+
+	sections := make([]Section, 0, len(byDay))
+	for day, arts := range byDay {
+		sections = append(sections, Section{Name: day, Articles: arts})
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].Name > sections[j].Name
+	})
+	return sections
+}
+
+// GroupByTag groups articles into one section per tag, largest sections first. An article
+// with several tags appears in each of their sections. Articles without tags are collected
+// into a final UNTAGGED section.
+func GroupByTag(articles []storage.Article) []Section {
+	byTag := make(map[string][]storage.Article)
+	var untagged []storage.Article
+	for _, art := range articles {
+		if len(art.Tags) == 0 {
+			untagged = append(untagged, art)
+			continue
+		}
+		for _, tag := range art.Tags {
+			byTag[tag] = append(byTag[tag], art)
+		}
+	}
+
+	sections := make([]Section, 0, len(byTag)+1)
+	for tag, arts := range byTag {
+		sections = append(sections, Section{Name: tag, Articles: arts})
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		if len(sections[i].Articles) != len(sections[j].Articles) {
+			return len(sections[i].Articles) > len(sections[j].Articles)
+		}
+		return sections[i].Name < sections[j].Name
+	})
+
+	if len(untagged) > 0 {
+		sections = append(sections, Section{Name: UNTAGGED, Articles: untagged})
+	}
+	return sections
+}
+
+// Generate renders the report in the given format.
+func (r *Report) Generate(format string) (string, error) {
+	switch format {
+	case FORMAT_JSON:
+		return r.GenerateJSON()
+	case FORMAT_ATOM:
+		return r.GenerateAtom()
+	case FORMAT_RSS:
+		return r.GenerateRSS()
+	case FORMAT_JSONFEED:
+		return r.GenerateJSONFeed()
+	}
+
+	tmpl, err := r.loadTemplate(format)
 	if err != nil {
-		return "", fmt.Errorf("error parsing report template: %w", err)
+		return "", err
 	}
 
 	var buf bytes.Buffer
@@ -110,20 +328,31 @@ func (r *Report) GenerateHTML() (string, error) {
 	return buf.String(), nil
 }
 
-// GenerateFile creates an HTML report from the given articles and writes it to the specified file.
-func (r *Report) GenerateFile(filename string) error {
-	html, err := r.GenerateHTML()
+// GenerateHTML generates the HTML report string from the given articles.
+func (r *Report) GenerateHTML() (string, error) {
+	return r.Generate(FORMAT_HTML)
+}
+
+// GenerateFile creates a report in the given format and writes it to the specified file,
+// or to standard output if the filename is "-".
+func (r *Report) GenerateFile(filename, format string) error {
+	content, err := r.Generate(format)
 	if err != nil {
 		return err
 	}
 
+	if filename == "-" {
+		_, err := os.Stdout.WriteString(content)
+		return err
+	}
+
 	f, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("error creating report file %s: %w", filename, err)
 	}
 	defer utils.CloseFileBOF(f)
 
-	if _, err := f.WriteString(html); err != nil {
+	if _, err := f.WriteString(content); err != nil {
 		return fmt.Errorf("error writing report file: %w", err)
 	}
 
@@ -132,9 +361,50 @@ func (r *Report) GenerateFile(filename string) error {
 	return nil
 }
 
-// GenerateEmail sends the report via email.
-func (r *Report) GenerateEmail(to, from, smarthost, identity, username, password string) error {
-	if smarthost == "" || to == "" || from == "" {
+// Extension returns the usual file extension for the format.
+func Extension(format string) string {
+	switch format {
+	case FORMAT_MARKDOWN:
+		return ".md"
+	case FORMAT_TEXT:
+		return ".txt"
+	case FORMAT_JSON:
+		return ".json"
+	case FORMAT_ATOM:
+		return ".atom"
+	case FORMAT_RSS:
+		return ".rss"
+	case FORMAT_JSONFEED:
+		return ".feed.json"
+	default:
+		return ".html"
+	}
+}
+
+// ValidFormat returns true if the format is one of FORMATS.
+func ValidFormat(format string) bool {
+	for _, f := range FORMATS {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidSort returns true if the order is one of SORTS.
+func ValidSort(order string) bool {
+	return slices.Contains(SORTS, order)
+}
+
+// ValidGroup returns true if the grouping is one of GROUPS, or empty for no grouping.
+func ValidGroup(by string) bool {
+	return by == "" || slices.Contains(GROUPS, by)
+}
+
+// GenerateEmail sends the report via email. The message's subject defaults to the report
+// title, and its body is filled in with the HTML report and a plain text alternative.
+func (r *Report) GenerateEmail(msg email.Message, cfg email.Config) error {
+	if cfg.Smarthost == "" || len(msg.Recipients()) == 0 || msg.From == "" {
 		return fmt.Errorf("email configuration missing (smarthost, to, from)")
 	}
 
@@ -142,9 +412,16 @@ func (r *Report) GenerateEmail(to, from, smarthost, identity, username, password
 	if err != nil {
 		return fmt.Errorf("error generating html for email: %w", err)
 	}
+	text, err := r.Generate(FORMAT_TEXT)
+	if err != nil {
+		return fmt.Errorf("error generating text for email: %w", err)
+	}
 
-	if err := email.Send([]string{to}, from, r.Title, html, smarthost, identity, username, password); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+	if msg.Subject == "" {
+		msg.Subject = r.Title
 	}
-	return nil
+	msg.HTML = html
+	msg.Text = text
+
+	return email.Send(cfg, msg)
 }
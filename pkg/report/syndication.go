@@ -0,0 +1,293 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// SCORE_SCHEME identifies the category that carries an article's score in Atom and RSS
+// feeds, so feed readers can tell it apart from the topic tags.
+const SCORE_SCHEME = "urn:ai-rss-scraper:score"
+
+// JSON_FEED_VERSION is the version of the JSON Feed specification that is produced.
+const JSON_FEED_VERSION = "https://jsonfeed.org/version/1.1"
+
+// entryID returns a permanent identifier for an article. GUIDs are usually URLs already;
+// anything else is turned into a URN, since Atom requires an IRI.
+func entryID(guid string) string {
+	if u, err := url.Parse(guid); err == nil && u.Scheme != "" && u.Host != "" {
+		return guid
+	}
+	return "urn:ai-rss-scraper:article:" + url.PathEscape(guid)
+}
+
+// entryTitle returns the title to publish, preferring the translation if there is one.
+func entryTitle(art storage.Article) string {
+	if art.TranslatedTitle != "" {
+		return art.TranslatedTitle
+	}
+	return art.Title
+}
+
+// entries returns the articles to publish as feed entries. Articles folded into a story
+// are published as entries of their own, since they met the threshold too.
+func (r *Report) entries() []storage.Article {
+	var entries []storage.Article
+	for _, art := range r.Articles {
+		entries = append(entries, art)
+		entries = append(entries, art.Related...)
+	}
+	return entries
+}
+
+// feedURL returns the absolute URL of a path on the site serving the report, or an empty
+// string if the site URL is not known.
+func (r *Report) feedURL(path string) string {
+	if r.SiteURL == "" {
+		return ""
+	}
+	return strings.TrimRight(r.SiteURL, "/") + path
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term   string `xml:"term,attr"`
+	Scheme string `xml:"scheme,attr,omitempty"`
+	Label  string `xml:"label,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// GenerateAtom renders the report as an Atom feed. Each entry's summary is the AI
+// analysis, and its score is a category in the SCORE_SCHEME scheme.
+func (r *Report) GenerateAtom() (string, error) {
+	feed := atomFeed{
+		ID:      "urn:ai-rss-scraper:report",
+		Title:   r.Title,
+		Updated: r.GeneratedAt.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "ai-rss-scraper"},
+	}
+	if self := r.feedURL("/feed.xml"); self != "" {
+		feed.ID = self
+		feed.Links = append(feed.Links, atomLink{Href: self, Rel: "self", Type: "application/atom+xml"})
+		feed.Links = append(feed.Links, atomLink{Href: r.feedURL("/"), Rel: "alternate", Type: "text/html"})
+	}
+
+	for _, art := range r.entries() {
+		entry := atomEntry{
+			ID:        entryID(art.GUID),
+			Title:     entryTitle(art),
+			Links:     []atomLink{{Href: art.Link, Rel: "alternate"}},
+			Published: art.PublishedDate.UTC().Format(time.RFC3339),
+			Updated:   art.PublishedDate.UTC().Format(time.RFC3339),
+		}
+		if name := r.FeedName(art.FeedURL); name != "" {
+			entry.Author = &atomAuthor{Name: name}
+		}
+		if art.Score != "" {
+			entry.Categories = append(entry.Categories, atomCategory{Term: art.Score, Scheme: SCORE_SCHEME, Label: "Score " + art.Score})
+		}
+		for _, tag := range art.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if art.Analysis != "" {
+			entry.Summary = &atomText{Type: "text", Body: art.Analysis}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return marshalXML(feed)
+}
+
+type rssCategory struct {
+	Domain string `xml:"domain,attr,omitempty"`
+	Name   string `xml:",chardata"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Source      string        `xml:"source,omitempty"`
+	Categories  []rssCategory `xml:"category"`
+	Description string        `xml:"description,omitempty"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// GenerateRSS renders the report as an RSS 2.0 feed. Each item's description is the AI
+// analysis, and its score is a category in the SCORE_SCHEME domain.
+func (r *Report) GenerateRSS() (string, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         r.Title,
+			Link:          r.feedURL("/"),
+			Description:   fmt.Sprintf("Articles scoring %d or more", r.Threshold),
+			LastBuildDate: r.GeneratedAt.Format(time.RFC1123Z),
+		},
+	}
+
+	for _, art := range r.entries() {
+		item := rssItem{
+			Title:       entryTitle(art),
+			Link:        art.Link,
+			GUID:        rssGUID{IsPermaLink: false, Value: art.GUID},
+			PubDate:     art.PublishedDate.Format(time.RFC1123Z),
+			Description: art.Analysis,
+		}
+		if art.Score != "" {
+			item.Categories = append(item.Categories, rssCategory{Domain: SCORE_SCHEME, Name: art.Score})
+		}
+		for _, tag := range art.Tags {
+			item.Categories = append(item.Categories, rssCategory{Name: tag})
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	return marshalXML(feed)
+}
+
+// marshalXML renders a feed as an indented XML document.
+func marshalXML(feed any) (string, error) {
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding feed: %w", err)
+	}
+	return xml.Header + string(out) + "\n", nil
+}
+
+// JSONFeedItem is an entry in a JSON Feed. The score is also published in the
+// "_ai_rss_scraper" extension object, as a number.
+type JSONFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary,omitempty"`
+	ContentText   string           `json:"content_text"`
+	DatePublished time.Time        `json:"date_published"`
+	Tags          []string         `json:"tags,omitempty"`
+	Authors       []JSONFeedAuthor `json:"authors,omitempty"`
+	Extension     *JSONFeedScore   `json:"_ai_rss_scraper,omitempty"`
+}
+
+// JSONFeedAuthor is the author of a JSON Feed item, which is the feed it came from.
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// JSONFeedScore is the "_ai_rss_scraper" extension of a JSON Feed item.
+type JSONFeedScore struct {
+	Score int `json:"score"`
+}
+
+// JSONFeedDocument is a JSON Feed document.
+type JSONFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+// GenerateJSONFeed renders the report as a JSON Feed. Each item's content is the AI
+// analysis, and its score is both a "score:N" tag and a number in the extension object.
+func (r *Report) GenerateJSONFeed() (string, error) {
+	feed := JSONFeedDocument{
+		Version:     JSON_FEED_VERSION,
+		Title:       r.Title,
+		HomePageURL: r.feedURL("/"),
+		FeedURL:     r.feedURL("/feed.json"),
+		Description: fmt.Sprintf("Articles scoring %d or more", r.Threshold),
+		Items:       []JSONFeedItem{},
+	}
+
+	for _, art := range r.entries() {
+		item := JSONFeedItem{
+			ID:            art.GUID,
+			URL:           art.Link,
+			Title:         entryTitle(art),
+			Summary:       art.TranslatedSummary,
+			ContentText:   art.Analysis,
+			DatePublished: art.PublishedDate,
+		}
+		if name := r.FeedName(art.FeedURL); name != "" {
+			item.Authors = []JSONFeedAuthor{{Name: name}}
+		}
+		if score, err := strconv.Atoi(art.Score); err == nil {
+			item.Tags = append(item.Tags, "score:"+art.Score)
+			item.Extension = &JSONFeedScore{Score: score}
+		}
+		item.Tags = append(item.Tags, art.Tags...)
+		feed.Items = append(feed.Items, item)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		return "", fmt.Errorf("error encoding json feed: %w", err)
+	}
+	return buf.String(), nil
+}
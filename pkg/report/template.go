@@ -0,0 +1,208 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// TEMPLATE_NAME is the name of the main HTML template. When the report template is a
+// directory, this is the file within it that is rendered; any other *.html files in the
+// directory are parsed too, so they can hold shared {{define}} blocks.
+const TEMPLATE_NAME = "report.html"
+
+// templateNames maps each template-based format to the name of its template file. A
+// template directory may provide any of them; missing ones fall back to the defaults.
+var templateNames = map[string]string{
+	FORMAT_HTML:     TEMPLATE_NAME,
+	FORMAT_MARKDOWN: "report.md",
+	FORMAT_TEXT:     "report.txt",
+}
+
+// defaultTemplates holds the built-in templates, used unless custom ones are configured.
+//
+//go:embed templates
+var defaultTemplates embed.FS
+
+// executor is implemented by both html/template and text/template templates.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// hostname returns the host part of a URL, for displaying where an article came from.
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// funcs returns the helper functions available to report templates.
+func (r *Report) funcs() map[string]any {
+	return map[string]any{
+		"hostname":    hostname,
+		"feedName":    r.FeedName,
+		"groupByFeed": r.GroupByFeed,
+		"groupByTag":  GroupByTag,
+		"groupByDay":  GroupByDay,
+		"join":        strings.Join,
+		"lower":       strings.ToLower,
+		"upper":       strings.ToUpper,
+		"formatTime": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+	}
+}
+
+// loadTemplate parses the report's template for the given format: the embedded default, a
+// single file (HTML only), or the format's file in a template directory.
+func (r *Report) loadTemplate(format string) (executor, error) {
+	name, ok := templateNames[format]
+	if !ok {
+		return nil, fmt.Errorf("format %s does not use a template", format)
+	}
+
+	path := ""
+	if r.TemplatePath != "" {
+		info, err := os.Stat(r.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading report template: %w", err)
+		}
+		if !info.IsDir() {
+			if format == FORMAT_HTML {
+				return r.parseTemplate(format, filepath.Base(r.TemplatePath), r.TemplatePath)
+			}
+		} else if _, err := os.Stat(filepath.Join(r.TemplatePath, name)); err == nil {
+			path = r.TemplatePath
+		} else if format == FORMAT_HTML {
+			return nil, fmt.Errorf("report template directory %s has no %s", r.TemplatePath, name)
+		}
+	}
+
+	if path == "" {
+		content, err := defaultTemplates.ReadFile("templates/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading built-in report template: %w", err)
+		}
+		var tmpl executor
+		if format == FORMAT_HTML {
+			tmpl, err = htmltemplate.New(name).Funcs(r.funcs()).Parse(string(content))
+		} else {
+			tmpl, err = texttemplate.New(name).Funcs(r.funcs()).Parse(string(content))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing built-in report template: %w", err)
+		}
+		return tmpl, nil
+	}
+
+	// Parse every file with the same extension, so shared {{define}} blocks are available.
+	return r.parseTemplate(format, name, filepath.Join(path, "*"+filepath.Ext(name)))
+}
+
+// parseTemplate parses the files matching the glob pattern with the template package that
+// suits the format, and returns the named template.
+func (r *Report) parseTemplate(format, name, pattern string) (executor, error) {
+	if format == FORMAT_HTML {
+		tmpl, err := htmltemplate.New(name).Funcs(r.funcs()).ParseGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing report template %s: %w", pattern, err)
+		}
+		return tmpl.Lookup(name), nil
+	}
+
+	tmpl, err := texttemplate.New(name).Funcs(r.funcs()).ParseGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing report template %s: %w", pattern, err)
+	}
+	return tmpl.Lookup(name), nil
+}
+
+// sampleArticles returns made-up articles that exercise the optional parts of a template.
+func sampleArticles() []storage.Article {
+	now := time.Now()
+	return []storage.Article{
+		{
+			GUID:          "sample-1",
+			Title:         "Restoring a Z80 single board computer",
+			Link:          "https://example.com/z80-restoration",
+			Description:   "A look at bringing a 1970s Z80 board back to life.",
+			Content:       "The board needed new capacitors and a replacement EPROM.",
+			PublishedDate: now.Add(-2 * time.Hour),
+			Score:         "92",
+			Analysis:      "Score: 92\n- Z80\n- Restoration\n- Vintage hardware",
+			FeedURL:       "https://example.com/feed/",
+			Model:         "sample-model",
+			Tags:          []string{"z80", "restoration"},
+			Duplicates: []storage.Article{
+				{GUID: "sample-1-dup", Title: "Z80 board restored", Link: "https://aggregator.example.org/z80", FeedURL: "https://aggregator.example.org/rss"},
+			},
+			Related: []storage.Article{
+				{GUID: "sample-3", Title: "More Z80 restoration tips", Link: "https://example.net/z80-tips", Score: "70", FeedURL: "https://example.net/rss"},
+			},
+		},
+		{
+			GUID:              "sample-2",
+			Title:             "Nixie-Röhren-Uhr selbst gebaut",
+			Link:              "https://example.de/nixie",
+			Description:       "Eine Uhr mit Nixie-Röhren und einem Mikrocontroller.",
+			PublishedDate:     now.Add(-26 * time.Hour),
+			Score:             "75",
+			Analysis:          "Score: 75\n- Nixie tubes",
+			FeedURL:           "https://example.de/feed/",
+			Model:             "sample-model",
+			Language:          "de",
+			TranslatedTitle:   "Home-built nixie tube clock",
+			TranslatedSummary: "A clock using nixie tubes and a microcontroller.",
+		},
+	}
+}
+
+// SampleReport returns a report of made-up articles, for checking templates and testing
+// delivery.
+func SampleReport() *Report {
+	r := NewReport("Sample Report", sampleArticles())
+	r.Threshold = 50
+	r.AgeDays = 7
+	r.FeedNames = map[string]string{"https://example.com/feed/": "Example Blog"}
+	r.Omitted = 3
+	r.MoreURL = "https://example.com/scraper/"
+	return r
+}
+
+// CheckTemplate renders the templates at the given path (or the default templates, if the
+// path is empty) for every template-based format with sample data, and returns any error
+// from parsing or executing them.
+func CheckTemplate(path string) error {
+	for _, format := range []string{FORMAT_HTML, FORMAT_MARKDOWN, FORMAT_TEXT} {
+		r := SampleReport()
+		r.TemplatePath = path
+
+		tmpl, err := r.loadTemplate(format)
+		if err != nil {
+			return err
+		}
+
+		// Render both with and without sections, since templates usually branch on them.
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, r); err != nil {
+			return fmt.Errorf("error executing %s report template: %w", format, err)
+		}
+		r.GroupByTag()
+		if err := tmpl.Execute(&buf, r); err != nil {
+			return fmt.Errorf("error executing %s report template with sections: %w", format, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// at returns the time in UTC, to the second.
+func at(year int, month time.Month, day, hour, minute, second int) time.Time {
+	return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"-1 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"a * * * *",
+		"* * * smarch *",
+		"* * * * funday",
+		"*/0 * * * *",
+		"*/-5 * * * *",
+		"*/x * * * *",
+		"30-10 * * * *",
+		"* * * * fri-mon",
+		"1,,2 * * * *",
+		"@every",
+		"@every 500ms",
+		"@every soon",
+		"@fortnightly",
+		"CRON_TZ=Mars/Olympus_Mons 0 7 * * *",
+	}
+	for _, spec := range tests {
+		if _, err := Parse(spec, time.UTC); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-01-01 is a Monday.
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", at(2024, 1, 1, 10, 30, 45), at(2024, 1, 1, 10, 31, 0)},
+		{"strictly after a matching time", "* * * * *", at(2024, 1, 1, 10, 30, 0), at(2024, 1, 1, 10, 31, 0)},
+		{"fixed time later today", "0 7 * * *", at(2024, 1, 1, 6, 59, 59), at(2024, 1, 1, 7, 0, 0)},
+		{"fixed time tomorrow", "0 7 * * *", at(2024, 1, 1, 7, 0, 0), at(2024, 1, 2, 7, 0, 0)},
+		{"minute list", "0,20,40 * * * *", at(2024, 1, 1, 10, 21, 0), at(2024, 1, 1, 10, 40, 0)},
+		{"minute step", "*/15 * * * *", at(2024, 1, 1, 10, 31, 0), at(2024, 1, 1, 10, 45, 0)},
+		{"minute step wraps to the next hour", "*/15 * * * *", at(2024, 1, 1, 10, 45, 0), at(2024, 1, 1, 11, 0, 0)},
+		{"step from a start value", "5/15 * * * *", at(2024, 1, 1, 10, 21, 0), at(2024, 1, 1, 10, 35, 0)},
+		{"step from a start value wraps", "5/15 * * * *", at(2024, 1, 1, 10, 51, 0), at(2024, 1, 1, 11, 5, 0)},
+		{"stepped range", "10-20/5 * * * *", at(2024, 1, 1, 10, 16, 0), at(2024, 1, 1, 10, 20, 0)},
+		{"stepped range ends at its end", "10-20/5 * * * *", at(2024, 1, 1, 10, 21, 0), at(2024, 1, 1, 11, 10, 0)},
+		{"stepped hour range", "0 9-17/4 * * *", at(2024, 1, 1, 13, 1, 0), at(2024, 1, 1, 17, 0, 0)},
+		{"stepped hour range skips to the next day", "0 9-17/4 * * *", at(2024, 1, 1, 17, 0, 0), at(2024, 1, 2, 9, 0, 0)},
+		{"weekdays skip the weekend", "0 7 * * 1-5", at(2024, 1, 5, 8, 0, 0), at(2024, 1, 8, 7, 0, 0)},
+		{"day names", "0 7 * * mon-fri", at(2024, 1, 5, 8, 0, 0), at(2024, 1, 8, 7, 0, 0)},
+		{"day names ignore case", "0 7 * * SAT", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 6, 7, 0, 0)},
+		{"sunday is 0", "0 0 * * 0", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 7, 0, 0, 0)},
+		{"sunday is 7", "0 0 * * 7", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 7, 0, 0, 0)},
+		{"range ending in 7 includes sunday", "0 0 * * 6-7", at(2024, 1, 6, 12, 0, 0), at(2024, 1, 7, 0, 0, 0)},
+		{"days of month", "0 0 1,15 * *", at(2024, 1, 2, 0, 0, 0), at(2024, 1, 15, 0, 0, 0)},
+		{"day of month only", "0 0 13 * *", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 13, 0, 0, 0)},
+		{"day of week only", "0 0 * * 5", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 5, 0, 0, 0)},
+		{"day of month or week, week first", "0 0 13 * 5", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 5, 0, 0, 0)},
+		{"day of month or week, month first", "0 0 13 * 5", at(2024, 1, 12, 1, 0, 0), at(2024, 1, 13, 0, 0, 0)},
+		{"question mark is any day", "0 0 13 * ?", at(2024, 1, 1, 0, 0, 0), at(2024, 1, 13, 0, 0, 0)},
+		{"month names", "0 12 1 jan,jul *", at(2024, 2, 1, 0, 0, 0), at(2024, 7, 1, 12, 0, 0)},
+		{"month wraps to the next year", "0 0 1 3 *", at(2024, 3, 1, 0, 0, 0), at(2025, 3, 1, 0, 0, 0)},
+		{"day missing from some months", "0 0 31 * *", at(2024, 4, 1, 0, 0, 0), at(2024, 5, 31, 0, 0, 0)},
+		{"leap day", "0 0 29 2 *", at(2024, 3, 1, 0, 0, 0), at(2028, 2, 29, 0, 0, 0)},
+		{"impossible date", "0 0 30 2 *", at(2024, 1, 1, 0, 0, 0), time.Time{}},
+		{"hourly", "@hourly", at(2024, 1, 1, 10, 30, 0), at(2024, 1, 1, 11, 0, 0)},
+		{"daily", "@daily", at(2024, 1, 1, 10, 30, 0), at(2024, 1, 2, 0, 0, 0)},
+		{"weekly", "@weekly", at(2024, 1, 1, 10, 30, 0), at(2024, 1, 7, 0, 0, 0)},
+		{"monthly", "@monthly", at(2024, 1, 1, 10, 30, 0), at(2024, 2, 1, 0, 0, 0)},
+		{"yearly", "@yearly", at(2024, 1, 1, 10, 30, 0), at(2025, 1, 1, 0, 0, 0)},
+		{"interval", "@every 90m", at(2024, 1, 1, 10, 30, 15), at(2024, 1, 1, 12, 0, 15)},
+		{"extra whitespace", "  0   7 * *   *  ", at(2024, 1, 1, 8, 0, 0), at(2024, 1, 2, 7, 0, 0)},
+		{"own timezone", "CRON_TZ=Europe/Berlin 0 7 * * *", at(2024, 1, 1, 10, 0, 0), at(2024, 1, 2, 6, 0, 0)},
+		{"own timezone in summer", "CRON_TZ=Europe/Berlin 0 7 * * *", at(2024, 7, 1, 10, 0, 0), at(2024, 7, 2, 5, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, time.UTC)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Parse("0 9 * * *", tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Location() != tokyo {
+		t.Errorf("Location() = %v, want %v", s.Location(), tokyo)
+	}
+
+	// 09:00 in Tokyo is 00:00 UTC.
+	got := s.Next(at(2024, 1, 1, 1, 0, 0))
+	if want := at(2024, 1, 2, 0, 0, 0); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	if got.Location() != tokyo {
+		t.Errorf("Next() is in %v, want %v", got.Location(), tokyo)
+	}
+}
+
+func TestParseTimezonePrefix(t *testing.T) {
+	s, err := Parse("CRON_TZ=America/New_York @daily", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Location().String(); got != "America/New_York" {
+		t.Errorf("Location() = %s, want America/New_York", got)
+	}
+	if got := s.String(); got != "CRON_TZ=America/New_York @daily" {
+		t.Errorf("String() = %q, want the expression as given", got)
+	}
+}
+
+func TestParseNilLocation(t *testing.T) {
+	s, err := Parse("@hourly", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Location() != time.Local {
+		t.Errorf("Location() = %v, want local time", s.Location())
+	}
+}
@@ -0,0 +1,100 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+)
+
+// StageHealth records how the runs of one stage of the scraper (fetch, score, report...)
+// have gone.
+type StageHealth struct {
+	Name     string
+	Runs     int
+	Failures int
+
+	// ConsecutiveFailures is reset to 0 by a successful run.
+	ConsecutiveFailures int
+
+	LastRun     time.Time
+	LastSuccess time.Time
+	LastError   string
+}
+
+// Health tracks the failures of each stage against a failure budget: the number of
+// consecutive failures of any one stage after which the process should give up.
+type Health struct {
+	mu     sync.Mutex
+	budget int
+	stages []*StageHealth
+}
+
+// NewHealth returns a tracker with the given failure budget. A budget of 0 or less means
+// never give up.
+func NewHealth(budget int) *Health {
+	return &Health{budget: budget}
+}
+
+// Budget returns the failure budget.
+func (h *Health) Budget() int {
+	return h.budget
+}
+
+// stage returns the named stage, adding it if it is new. The caller must hold the lock.
+func (h *Health) stage(name string) *StageHealth {
+	for _, s := range h.stages {
+		if s.Name == name {
+			return s
+		}
+	}
+	s := &StageHealth{Name: name}
+	h.stages = append(h.stages, s)
+	return s
+}
+
+// Record records the outcome of a run of the named stage, and returns the stage's health.
+func (h *Health) Record(name string, err error) StageHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.stage(name)
+	s.Runs++
+	s.LastRun = time.Now()
+	if err != nil {
+		s.Failures++
+		s.ConsecutiveFailures++
+		s.LastError = err.Error()
+	} else {
+		s.ConsecutiveFailures = 0
+		s.LastSuccess = s.LastRun
+		s.LastError = ""
+	}
+	return *s
+}
+
+// Exhausted returns the first stage that has used up the failure budget, if any.
+func (h *Health) Exhausted() (StageHealth, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.budget <= 0 {
+		return StageHealth{}, false
+	}
+	for _, s := range h.stages {
+		if s.ConsecutiveFailures >= h.budget {
+			return *s, true
+		}
+	}
+	return StageHealth{}, false
+}
+
+// Stages returns the health of every stage that has run, in the order they first ran.
+func (h *Health) Stages() []StageHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stages := make([]StageHealth, 0, len(h.stages))
+	for _, s := range h.stages {
+		stages = append(stages, *s)
+	}
+	return stages
+}
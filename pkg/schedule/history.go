@@ -0,0 +1,116 @@
+package schedule
+
+import (
+	"sync"
+	"time"
+)
+
+// What started a run.
+const (
+	TRIGGER_INTERVAL = "interval"
+	TRIGGER_SCHEDULE = "schedule"
+	TRIGGER_MANUAL   = "manual"
+)
+
+// MAX_RUN_OUTPUT is the most output kept for a single run. Anything beyond it is dropped.
+const MAX_RUN_OUTPUT = 64 * 1024
+
+// Run is a record of one run of a stage of the scraper, including its output.
+type Run struct {
+	ID       int
+	Stage    string
+	Trigger  string
+	Started  time.Time
+	Finished time.Time
+	Error    string
+	Output   string
+
+	output []byte
+}
+
+// Running returns true if the run has not finished yet.
+func (r Run) Running() bool {
+	return r.Finished.IsZero()
+}
+
+// Duration returns how long the run took, or has taken so far.
+func (r Run) Duration() time.Duration {
+	if r.Running() {
+		return time.Since(r.Started)
+	}
+	return r.Finished.Sub(r.Started)
+}
+
+// History keeps the most recent runs. It is also an io.Writer: output written to it is
+// added to the most recently started run.
+type History struct {
+	mu     sync.Mutex
+	max    int
+	nextID int
+	runs   []*Run
+}
+
+// NewHistory returns a history holding up to max runs.
+func NewHistory(max int) *History {
+	return &History{max: max, nextID: 1}
+}
+
+// Start records the start of a run of the stage, and returns its ID.
+func (h *History) Start(stage, trigger string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	run := &Run{ID: h.nextID, Stage: stage, Trigger: trigger, Started: time.Now()}
+	h.nextID++
+	h.runs = append(h.runs, run)
+	if len(h.runs) > h.max {
+		h.runs = h.runs[len(h.runs)-h.max:]
+	}
+	return run.ID
+}
+
+// Finish records the end of the run with the given ID, and its error, if any.
+func (h *History) Finish(id int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, run := range h.runs {
+		if run.ID == id {
+			run.Finished = time.Now()
+			if err != nil {
+				run.Error = err.Error()
+			}
+		}
+	}
+}
+
+// Write adds output to the most recently started run, including output that arrives after
+// it has finished and before the next run starts. Output written before any run has started
+// is dropped.
+func (h *History) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.runs) > 0 {
+		run := h.runs[len(h.runs)-1]
+		if room := MAX_RUN_OUTPUT - len(run.output); room > 0 {
+			run.output = append(run.output, p[:min(len(p), room)]...)
+		}
+	}
+	return len(p), nil
+}
+
+// Runs returns the recorded runs, most recent first.
+func (h *History) Runs() []Run {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	runs := make([]Run, 0, len(h.runs))
+	for i := len(h.runs) - 1; i >= 0; i-- {
+		run := *h.runs[i]
+		run.Output = string(run.output)
+		run.output = nil
+		runs = append(runs, run)
+	}
+	return runs
+}
@@ -0,0 +1,254 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned by Trigger.
+var (
+	ErrUnknownJob    = errors.New("no such job")
+	ErrAlreadyQueued = errors.New("already queued or running")
+)
+
+// Job is a task run by the scheduler whenever its schedule comes due, or when triggered.
+// A job with no schedule only runs when triggered. Run is passed the scheduler's context,
+// which it should return early if cancelled, and what started the run.
+type Job struct {
+	Name     string
+	Schedule *Schedule
+	Run      func(ctx context.Context, trigger string) error
+}
+
+// JobStatus describes a job's schedule and its most recent run, for display.
+type JobStatus struct {
+	Name     string
+	Spec     string
+	Location string
+	Next     time.Time
+
+	Queued  bool
+	Running bool
+
+	// LastStart is zero if the job has not run since the process started.
+	LastStart    time.Time
+	LastDuration time.Duration
+	LastError    string
+	Runs         int
+}
+
+// entry is a job along with its state.
+type entry struct {
+	job     Job
+	status  JobStatus
+	trigger string
+}
+
+// location returns the timezone of the job's schedule.
+func (e *entry) location() *time.Location {
+	if e.job.Schedule == nil {
+		return time.Local
+	}
+	return e.job.Schedule.Location()
+}
+
+// Scheduler runs jobs on their schedules, or when triggered, one at a time. Jobs that come
+// due while another job is running wait in a queue, so a slow fetch never overlaps with
+// scoring or a report. A job that is still queued or running when it comes due again is not
+// queued twice.
+type Scheduler struct {
+	mu      sync.Mutex
+	entries []*entry
+	queue   chan *entry
+
+	// OnFinish, if set, is called with the job's status after each run.
+	OnFinish func(JobStatus)
+}
+
+// New returns an empty scheduler.
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add adds a job to the scheduler. Jobs must be added before calling Run.
+func (s *Scheduler) Add(job Job) {
+	status := JobStatus{Name: job.Name, Spec: "manual"}
+	if job.Schedule != nil {
+		status.Spec = job.Schedule.String()
+		status.Location = job.Schedule.Location().String()
+	}
+	s.entries = append(s.entries, &entry{job: job, status: status})
+}
+
+// Jobs returns the status of every job, soonest next run first, and those that only run
+// when triggered last.
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		jobs = append(jobs, e.status)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Next.IsZero() || jobs[j].Next.IsZero() {
+			return !jobs[i].Next.IsZero() && jobs[j].Next.IsZero()
+		}
+		return jobs[i].Next.Before(jobs[j].Next)
+	})
+	return jobs
+}
+
+// Run queues each job whenever its schedule comes due, and runs the queued jobs in order,
+// until the context is cancelled. It returns once the job running at that time, if any, has
+// returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	now := time.Now()
+	s.mu.Lock()
+	s.queue = make(chan *entry, len(s.entries))
+	for _, e := range s.entries {
+		if e.job.Schedule == nil {
+			continue
+		}
+		e.status.Next = e.job.Schedule.Next(now)
+		log.Printf("Scheduled %s (%s, %s): next run at %s", e.job.Name, e.status.Spec, e.status.Location, formatNext(e.status.Next))
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.work(ctx)
+	}()
+
+	for {
+		timer := time.NewTimer(time.Until(s.nextDue()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			wg.Wait()
+			return
+		case <-timer.C:
+		}
+		s.queueDue(time.Now())
+	}
+}
+
+// nextDue returns the earliest next run of any job, or a day from now if nothing is due,
+// so the loop wakes up occasionally regardless.
+func (s *Scheduler) nextDue() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := time.Now().Add(24 * time.Hour)
+	for _, e := range s.entries {
+		if !e.status.Next.IsZero() && e.status.Next.Before(next) {
+			next = e.status.Next
+		}
+	}
+	return next
+}
+
+// queueDue queues the jobs whose next run has come, and works out when they run next.
+func (s *Scheduler) queueDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.status.Next.IsZero() || e.status.Next.After(now) {
+			continue
+		}
+		e.status.Next = e.job.Schedule.Next(now)
+		if e.status.Queued || e.status.Running {
+			log.Printf("Skipping scheduled %s: previous run has not finished; next run at %s", e.job.Name, formatNext(e.status.Next))
+			continue
+		}
+		e.status.Queued = true
+		e.trigger = TRIGGER_SCHEDULE
+		s.queue <- e
+	}
+}
+
+// Trigger queues a run of the named job now, outside its schedule. It returns
+// ErrAlreadyQueued if the job is already queued or running.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queue == nil {
+		return errors.New("the scheduler is not running")
+	}
+	for _, e := range s.entries {
+		if e.job.Name != name {
+			continue
+		}
+		if e.status.Queued || e.status.Running {
+			return fmt.Errorf("%s: %w", name, ErrAlreadyQueued)
+		}
+		e.status.Queued = true
+		e.trigger = TRIGGER_MANUAL
+		s.queue <- e
+		log.Printf("Queued %s, requested manually", name)
+		return nil
+	}
+	return fmt.Errorf("%s: %w", name, ErrUnknownJob)
+}
+
+// work runs queued jobs one at a time until the context is cancelled.
+func (s *Scheduler) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.queue:
+			s.runJob(ctx, e)
+		}
+	}
+}
+
+// runJob runs a job and records the outcome.
+func (s *Scheduler) runJob(ctx context.Context, e *entry) {
+	s.mu.Lock()
+	e.status.Queued = false
+	e.status.Running = true
+	e.status.LastStart = time.Now().In(e.location())
+	trigger := e.trigger
+	s.mu.Unlock()
+
+	log.Printf("Running %s (%s)...", e.job.Name, trigger)
+	err := e.job.Run(ctx, trigger)
+
+	s.mu.Lock()
+	e.status.Running = false
+	e.status.LastDuration = time.Since(e.status.LastStart)
+	e.status.Runs++
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastError = err.Error()
+	}
+	status := e.status
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("%s failed after %s: %v; next run at %s", e.job.Name, status.LastDuration.Round(time.Second), err, formatNext(status.Next))
+	} else {
+		log.Printf("Finished %s in %s; next run at %s", e.job.Name, status.LastDuration.Round(time.Second), formatNext(status.Next))
+	}
+	if s.OnFinish != nil {
+		s.OnFinish(status)
+	}
+}
+
+// formatNext formats a next run time for the logs.
+func formatNext(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format("2006-01-02 15:04:05 MST")
+}
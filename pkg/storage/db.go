@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
 	"strings"
 	"time"
@@ -21,12 +22,103 @@ type Article struct {
 	FeedURL       string
 	Model         string
 	Reported      bool
+	Tags          []string
+
+	// Rating (1-5, or 0 if unrated), Read and Starred are set by the reader through the API.
+	Rating  int
+	Read    bool
+	Starred bool
+
+	// Language is the detected ISO 639-1 code of the article, or empty if unknown.
+	Language          string
+	TranslatedTitle   string
+	TranslatedSummary string
+
+	// CanonicalURL and Fingerprint identify near-duplicate articles across feeds. ClusterID
+	// is the GUID of the article that represents the cluster, or empty if it has none.
+	CanonicalURL string
+	Fingerprint  uint64
+	ClusterID    string
+
+	// Duplicates holds the other articles in the cluster, if loaded with LoadDuplicates.
+	Duplicates []Article
+
+	// Related holds other articles about the same story, when articles are grouped into
+	// stories for a report.
+	Related []Article
+}
+
+// IsDuplicate returns true if the article is a duplicate of another article, rather than
+// the representative of its cluster.
+func (a Article) IsDuplicate() bool {
+	return a.ClusterID != "" && a.ClusterID != a.GUID
+}
+
+// TagCount is a tag along with the number of articles carrying it.
+type TagCount struct {
+	Tag   string
+	Count int
 }
 
 type DB struct {
 	conn *sql.DB
 }
 
+// articleColumns is the list of columns selected by every query returning articles. It
+// must match the order of the fields in scanArticles.
+const articleColumns = `guid, title, link, description, COALESCE(content, ''), published_date, COALESCE(score, ''),
+	COALESCE(analysis, ''), COALESCE(feed_url, ''), COALESCE(model, ''), COALESCE(reported, 0),
+	COALESCE(language, ''), COALESCE(translated_title, ''), COALESCE(translated_summary, ''),
+	COALESCE(canonical_url, ''), COALESCE(fingerprint, 0), COALESCE(cluster_id, ''),
+	COALESCE(rating, 0), COALESCE(read, 0), COALESCE(starred, 0)`
+
+// inList matches a value against a list passed as a single parameter made by jsonList. Unlike
+// a placeholder per value, it works for lists longer than SQLite's limit on parameters.
+const inList = "IN (SELECT value FROM json_each(?))"
+
+// jsonList returns the values as a JSON array, to be passed as the parameter of inList.
+func jsonList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	// Marshalling strings cannot fail.
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// notDuplicate is a condition matching articles that are not duplicates of another article.
+const notDuplicate = `(cluster_id IS NULL OR cluster_id = '' OR cluster_id = guid)`
+
+// scanArticles reads all rows produced by a query selecting articleColumns.
+func scanArticles(rows *sql.Rows) ([]Article, error) {
+	var articles []Article
+	for rows.Next() {
+		var art Article
+		if err := scanArticle(rows, &art); err != nil {
+			return nil, err
+		}
+		articles = append(articles, art)
+	}
+	return articles, rows.Err()
+}
+
+// scanArticle reads the current row of a query selecting articleColumns, followed by any
+// extra columns, which are read into extra.
+func scanArticle(rows *sql.Rows, art *Article, extra ...any) error {
+	var fingerprint int64
+	dest := []any{&art.GUID, &art.Title, &art.Link, &art.Description, &art.Content, &art.PublishedDate, &art.Score,
+		&art.Analysis, &art.FeedURL, &art.Model, &art.Reported,
+		&art.Language, &art.TranslatedTitle, &art.TranslatedSummary,
+		&art.CanonicalURL, &fingerprint, &art.ClusterID,
+		&art.Rating, &art.Read, &art.Starred}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	// SQLite integers are signed, so the fingerprint is stored as its two's complement.
+	art.Fingerprint = uint64(fingerprint)
+	return nil
+}
+
 // closeRowsBOF closes the rows and bails on failure.
 func closeRowsBOF(rows *sql.Rows) {
 	err := rows.Close()
@@ -62,12 +154,95 @@ func NewDatabase(filepath string) (*DB, error) {
 		return nil, err
 	}
 
-	// Migration: Add reported column if it doesn't exist
-	_, err = db.Exec("ALTER TABLE articles ADD COLUMN reported BOOLEAN DEFAULT 0")
+	// Migrations: Add columns that did not exist in earlier versions
+	migrations := []string{
+		"ALTER TABLE articles ADD COLUMN reported BOOLEAN DEFAULT 0",
+		"ALTER TABLE articles ADD COLUMN language TEXT DEFAULT ''",
+		"ALTER TABLE articles ADD COLUMN translated_title TEXT DEFAULT ''",
+		"ALTER TABLE articles ADD COLUMN translated_summary TEXT DEFAULT ''",
+		"ALTER TABLE articles ADD COLUMN canonical_url TEXT DEFAULT ''",
+		"ALTER TABLE articles ADD COLUMN fingerprint INTEGER DEFAULT 0",
+		"ALTER TABLE articles ADD COLUMN cluster_id TEXT DEFAULT ''",
+		"ALTER TABLE articles ADD COLUMN rating INTEGER DEFAULT 0",
+		"ALTER TABLE articles ADD COLUMN read BOOLEAN DEFAULT 0",
+		"ALTER TABLE articles ADD COLUMN starred BOOLEAN DEFAULT 0",
+	}
+	for _, migration := range migrations {
+		_, err = db.Exec(migration)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return nil, err
+		}
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_articles_canonical_url ON articles (canonical_url);
+	CREATE INDEX IF NOT EXISTS idx_articles_cluster_id ON articles (cluster_id);`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(createFeedsSQL)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("ALTER TABLE feeds ADD COLUMN subscribed BOOLEAN DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return nil, err
+	}
+
+	_, err = db.Exec(createDeliveriesSQL)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("ALTER TABLE delivery_log ADD COLUMN report_id INTEGER DEFAULT 0")
 	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
 		return nil, err
 	}
 
+	_, err = db.Exec(createReportsSQL)
+	if err != nil {
+		return nil, err
+	}
+	reportMigrations := []string{
+		"ALTER TABLE reports ADD COLUMN html TEXT DEFAULT ''",
+		"ALTER TABLE reports ADD COLUMN group_by TEXT DEFAULT ''",
+		"ALTER TABLE reports ADD COLUMN sort_order TEXT DEFAULT ''",
+		"ALTER TABLE reports ADD COLUMN omitted INTEGER DEFAULT 0",
+	}
+	for _, migration := range reportMigrations {
+		_, err = db.Exec(migration)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return nil, err
+		}
+	}
+
+	createTagsSQL := `CREATE TABLE IF NOT EXISTS article_tags (
+		guid TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (guid, tag)
+	);
+	CREATE INDEX IF NOT EXISTS idx_article_tags_tag ON article_tags (tag);`
+
+	_, err = db.Exec(createTagsSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(createScoresSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(createPromptsSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The search index covers columns added by the migrations above, so comes last.
+	err = createSearchIndex(db)
+	if err != nil {
+		return nil, err
+	}
+
 	return &DB{conn: db}, nil
 }
 
@@ -84,18 +259,98 @@ func (d *DB) ArticleExists(guid string) (bool, error) {
 
 // SaveArticle saves a new article to the database.
 func (d *DB) SaveArticle(article Article) error {
-	query := `INSERT INTO articles (guid, title, link, description, content, published_date, score, analysis, feed_url, model) 
-              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := d.conn.Exec(query, article.GUID, article.Title, article.Link, article.Description, article.Content, article.PublishedDate, article.Score, article.Analysis, article.FeedURL, article.Model)
+	query := `INSERT INTO articles (guid, title, link, description, content, published_date, score, analysis, feed_url, model, language,
+              canonical_url, fingerprint, cluster_id) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := d.conn.Exec(query, article.GUID, article.Title, article.Link, article.Description, article.Content, article.PublishedDate, article.Score, article.Analysis, article.FeedURL, article.Model, article.Language,
+		article.CanonicalURL, int64(article.Fingerprint), article.ClusterID)
+	return err
+}
+
+// SetClusterID assigns the article to a cluster, if it is not already in one.
+func (d *DB) SetClusterID(guid, clusterID string) error {
+	_, err := d.conn.Exec("UPDATE articles SET cluster_id = ? WHERE guid = ? AND (cluster_id IS NULL OR cluster_id = '')", clusterID, guid)
 	return err
 }
 
+// reassignClusters makes the oldest remaining member of every cluster whose representative
+// has been deleted the cluster's new representative. Otherwise the other members would stay
+// duplicates of an article that no longer exists, and never be scored or reported.
+func reassignClusters(tx *sql.Tx) error {
+	representatives, err := orphanedClusters(tx)
+	if err != nil {
+		return err
+	}
+	for clusterID, guid := range representatives {
+		if _, err := tx.Exec("UPDATE articles SET cluster_id = ? WHERE cluster_id = ?", guid, clusterID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orphanedClusters returns the clusters whose representative no longer exists, along with
+// the oldest of their remaining members.
+func orphanedClusters(tx *sql.Tx) (map[string]string, error) {
+	query := `SELECT cluster_id, guid FROM articles a
+	          WHERE cluster_id != '' AND cluster_id NOT IN (SELECT guid FROM articles)
+	          AND guid = (SELECT guid FROM articles m WHERE m.cluster_id = a.cluster_id ORDER BY published_date, guid LIMIT 1)`
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	representatives := make(map[string]string)
+	for rows.Next() {
+		var clusterID, guid string
+		if err := rows.Scan(&clusterID, &guid); err != nil {
+			return nil, err
+		}
+		representatives[clusterID] = guid
+	}
+	return representatives, rows.Err()
+}
+
+// LoadDuplicates fills in the Duplicates field of each article with the other members of
+// its cluster.
+func (d *DB) LoadDuplicates(articles []Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(articles))
+	guids := make([]string, len(articles))
+	for i, art := range articles {
+		index[art.GUID] = i
+		guids[i] = art.GUID
+	}
+
+	query := "SELECT " + articleColumns + " FROM articles WHERE cluster_id " + inList + " AND guid != cluster_id ORDER BY published_date"
+	rows, err := d.conn.Query(query, jsonList(guids))
+	if err != nil {
+		return err
+	}
+	defer closeRowsBOF(rows)
+
+	duplicates, err := scanArticles(rows)
+	if err != nil {
+		return err
+	}
+	for _, dup := range duplicates {
+		if i, ok := index[dup.ClusterID]; ok {
+			articles[i].Duplicates = append(articles[i].Duplicates, dup)
+		}
+	}
+	return nil
+}
+
 // GetArticlesToScore retrieves articles to score.
 // If refreshPattern is empty, it returns unscored articles.
 // If refreshPattern is provided, it returns articles matching the title pattern (glob) OR unscored articles.
 func (d *DB) GetArticlesToScore(refreshPattern string) ([]Article, error) {
-	baseQuery := `SELECT guid, title, link, description, content, published_date, score, analysis, feed_url, model 
-              FROM articles WHERE score IS NULL OR score = '' OR score = 'N/A'`
+	baseQuery := `SELECT ` + articleColumns + `
+              FROM articles WHERE (score IS NULL OR score = '' OR score = 'N/A') AND ` + notDuplicate
 
 	var rows *sql.Rows
 	var err error
@@ -107,8 +362,8 @@ func (d *DB) GetArticlesToScore(refreshPattern string) ([]Article, error) {
 		likePattern := strings.ReplaceAll(refreshPattern, "*", "%")
 		likePattern = strings.ReplaceAll(likePattern, "?", "_")
 
-		query := `SELECT guid, title, link, description, content, published_date, score, analysis, feed_url, model 
-              FROM articles WHERE (score IS NULL OR score = '' OR score = 'N/A') OR title LIKE ?`
+		query := `SELECT ` + articleColumns + `
+              FROM articles WHERE ((score IS NULL OR score = '' OR score = 'N/A') OR title LIKE ?) AND ` + notDuplicate
 		rows, err = d.conn.Query(query, likePattern)
 	} else {
 		rows, err = d.conn.Query(baseQuery)
@@ -119,62 +374,94 @@ func (d *DB) GetArticlesToScore(refreshPattern string) ([]Article, error) {
 	}
 	defer closeRowsBOF(rows)
 
-	var articles []Article
-	for rows.Next() {
-		var art Article
-		err := rows.Scan(&art.GUID, &art.Title, &art.Link, &art.Description, &art.Content, &art.PublishedDate, &art.Score, &art.Analysis, &art.FeedURL, &art.Model)
-		if err != nil {
-			return nil, err
-		}
-		articles = append(articles, art)
+	return scanArticles(rows)
+}
+
+// ArticleState is an update to the reader's state for an article. Nil fields are left
+// unchanged.
+type ArticleState struct {
+	Rating  *int
+	Read    *bool
+	Starred *bool
+}
+
+// UpdateArticleState applies the update to the article with the given GUID, and returns
+// false if there is no such article.
+func (d *DB) UpdateArticleState(guid string, state ArticleState) (bool, error) {
+	var sets []string
+	var args []interface{}
+	if state.Rating != nil {
+		sets = append(sets, "rating = ?")
+		args = append(args, *state.Rating)
 	}
-	return articles, nil
+	if state.Read != nil {
+		sets = append(sets, "read = ?")
+		args = append(args, *state.Read)
+	}
+	if state.Starred != nil {
+		sets = append(sets, "starred = ?")
+		args = append(args, *state.Starred)
+	}
+	if len(sets) == 0 {
+		return d.ArticleExists(guid)
+	}
+
+	query := "UPDATE articles SET " + strings.Join(sets, ", ") + " WHERE guid = ?"
+	res, err := d.conn.Exec(query, append(args, guid)...)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
 }
 
-// UpdateArticleScore updates the score and analysis for a given article GUID.
-func (d *DB) UpdateArticleScore(guid, score, analysis, model string) error {
-	query := `UPDATE articles SET score = ?, analysis = ?, model = ? WHERE guid = ?`
-	_, err := d.conn.Exec(query, score, analysis, model, guid)
+// UpdateArticleTranslation stores the translated title and summary for a given article GUID.
+func (d *DB) UpdateArticleTranslation(guid, title, summary string) error {
+	query := `UPDATE articles SET translated_title = ?, translated_summary = ? WHERE guid = ?`
+	_, err := d.conn.Exec(query, title, summary, guid)
 	return err
 }
 
 // ListArticles retrieves the most recent articles, up to the specified limit.
 func (d *DB) ListArticles(limit int) ([]Article, error) {
-	return d.ListArticlesFiltered(limit, false)
+	return d.ListArticlesFiltered(limit, false, "")
 }
 
-// ListArticlesFiltered retrieves the most recent articles, optionally filtering by reported status.
-func (d *DB) ListArticlesFiltered(limit int, reportedOnly bool) ([]Article, error) {
-	var query string
+// ListArticlesFiltered retrieves the most recent articles, optionally filtering by reported status
+// and by tag. An empty tag matches all articles.
+func (d *DB) ListArticlesFiltered(limit int, reportedOnly bool, tag string) ([]Article, error) {
+	query := `SELECT ` + articleColumns + `
+              FROM articles WHERE 1=1`
+	var args []interface{}
 	if reportedOnly {
-		query = `SELECT guid, title, link, description, COALESCE(content, ''), published_date, score, analysis, COALESCE(feed_url, ''), COALESCE(model, ''), reported
-              FROM articles WHERE reported = 1 ORDER BY published_date DESC LIMIT ?`
-	} else {
-		query = `SELECT guid, title, link, description, COALESCE(content, ''), published_date, score, analysis, COALESCE(feed_url, ''), COALESCE(model, ''), reported
-              FROM articles ORDER BY published_date DESC LIMIT ?`
+		query += " AND reported = 1"
 	}
+	if tag != "" {
+		query += " AND guid IN (SELECT guid FROM article_tags WHERE tag = ?)"
+		args = append(args, tag)
+	}
+	query += " ORDER BY published_date DESC LIMIT ?"
+	args = append(args, limit)
 
-	rows, err := d.conn.Query(query, limit)
+	rows, err := d.conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer closeRowsBOF(rows)
 
-	var articles []Article
-	for rows.Next() {
-		var art Article
-		err := rows.Scan(&art.GUID, &art.Title, &art.Link, &art.Description, &art.Content, &art.PublishedDate, &art.Score, &art.Analysis, &art.FeedURL, &art.Model, &art.Reported)
-		if err != nil {
-			return nil, err
-		}
-		articles = append(articles, art)
+	articles, err := scanArticles(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.loadTags(articles); err != nil {
+		return nil, err
 	}
 	return articles, nil
 }
 
 // GetArticlesAfter retrieves articles published after the specified time.
 func (d *DB) GetArticlesAfter(since time.Time) ([]Article, error) {
-	query := `SELECT guid, title, link, description, content, published_date, score, analysis, feed_url, model, reported 
+	query := `SELECT ` + articleColumns + `
               FROM articles WHERE published_date >= ? ORDER BY published_date DESC`
 	rows, err := d.conn.Query(query, since)
 	if err != nil {
@@ -182,22 +469,19 @@ func (d *DB) GetArticlesAfter(since time.Time) ([]Article, error) {
 	}
 	defer closeRowsBOF(rows)
 
-	var articles []Article
-	for rows.Next() {
-		var art Article
-
-		err := rows.Scan(&art.GUID, &art.Title, &art.Link, &art.Description, &art.Content, &art.PublishedDate, &art.Score, &art.Analysis, &art.FeedURL, &art.Model, &art.Reported)
-		if err != nil {
-			return nil, err
-		}
-		articles = append(articles, art)
+	articles, err := scanArticles(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.loadTags(articles); err != nil {
+		return nil, err
 	}
 	return articles, nil
 }
 
 // GetUnreportedArticlesAfter retrieves unreported articles published after the specified time.
 func (d *DB) GetUnreportedArticlesAfter(since time.Time) ([]Article, error) {
-	query := `SELECT guid, title, link, description, content, published_date, score, analysis, feed_url, model, reported 
+	query := `SELECT ` + articleColumns + `
               FROM articles WHERE published_date >= ? AND (reported = 0 OR reported IS NULL) ORDER BY published_date DESC`
 	rows, err := d.conn.Query(query, since)
 	if err != nil {
@@ -205,14 +489,12 @@ func (d *DB) GetUnreportedArticlesAfter(since time.Time) ([]Article, error) {
 	}
 	defer closeRowsBOF(rows)
 
-	var articles []Article
-	for rows.Next() {
-		var art Article
-		err := rows.Scan(&art.GUID, &art.Title, &art.Link, &art.Description, &art.Content, &art.PublishedDate, &art.Score, &art.Analysis, &art.FeedURL, &art.Model, &art.Reported)
-		if err != nil {
-			return nil, err
-		}
-		articles = append(articles, art)
+	articles, err := scanArticles(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.loadTags(articles); err != nil {
+		return nil, err
 	}
 	return articles, nil
 }
@@ -223,18 +505,10 @@ func (d *DB) MarkArticlesReported(guids []string) error {
 		return nil
 	}
 
-	// Create placeholders and args for the SQL query.
-	//   placeholders is the list of ?
-	//   args is the list of guids
-	placeholders := make([]string, len(guids))
-	args := make([]interface{}, len(guids))
-	for i, id := range guids {
-		placeholders[i] = "?"
-		args[i] = id
-	}
-
-	query := "UPDATE articles SET reported = 1 WHERE guid IN (" + strings.Join(placeholders, ",") + ")"
-	_, err := d.conn.Exec(query, args...)
+	// Duplicates are reported along with the representative of their cluster.
+	list := jsonList(guids)
+	query := "UPDATE articles SET reported = 1 WHERE guid " + inList + " OR cluster_id " + inList
+	_, err := d.conn.Exec(query, list, list)
 	return err
 }
 
@@ -257,14 +531,8 @@ func (d *DB) ResetReportedArticles(guids []string) error {
 	if len(guids) == 0 {
 		return nil
 	}
-	placeholders := make([]string, len(guids))
-	args := make([]interface{}, len(guids))
-	for i, id := range guids {
-		placeholders[i] = "?"
-		args[i] = id
-	}
-	query := "UPDATE articles SET reported = 0 WHERE guid IN (" + strings.Join(placeholders, ",") + ")"
-	_, err := d.conn.Exec(query, args...)
+	query := "UPDATE articles SET reported = 0 WHERE guid " + inList
+	_, err := d.conn.Exec(query, jsonList(guids))
 	return err
 }
 
@@ -273,17 +541,126 @@ func (d *DB) ClearScores(guids []string) error {
 	if len(guids) == 0 {
 		return nil
 	}
-	placeholders := make([]string, len(guids))
-	args := make([]interface{}, len(guids))
-	for i, id := range guids {
-		placeholders[i] = "?"
-		args[i] = id
-	}
-	query := "UPDATE articles SET score = '', analysis = '', model = '' WHERE guid IN (" + strings.Join(placeholders, ",") + ")"
-	_, err := d.conn.Exec(query, args...)
+	query := "UPDATE articles SET score = '', analysis = '', model = '' WHERE guid " + inList
+	_, err := d.conn.Exec(query, jsonList(guids))
 	return err
 }
 
+// NormalizeTag returns a tag in the form it is stored in: lowercased, with punctuation trimmed
+// and whitespace collapsed to dashes.
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	tag = strings.Trim(tag, "\"'`*#.[]()")
+	return strings.Join(strings.Fields(tag), "-")
+}
+
+// SetArticleTags replaces the tags for the given article GUID.
+func (d *DB) SetArticleTags(guid string, tags []string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM article_tags WHERE guid = ?", guid); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO article_tags (guid, tag) VALUES (?, ?)", guid, tag); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListTags returns every tag in use along with the number of articles carrying it,
+// most common first.
+func (d *DB) ListTags() ([]TagCount, error) {
+	rows, err := d.conn.Query("SELECT tag, COUNT(*) FROM article_tags GROUP BY tag ORDER BY COUNT(*) DESC, tag")
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var tags []TagCount
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tc)
+	}
+	return tags, nil
+}
+
+// loadTags fills in the Tags field of each article.
+func (d *DB) loadTags(articles []Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(articles))
+	guids := make([]string, len(articles))
+	for i, art := range articles {
+		index[art.GUID] = i
+		guids[i] = art.GUID
+	}
+
+	query := "SELECT guid, tag FROM article_tags WHERE guid " + inList + " ORDER BY tag"
+	rows, err := d.conn.Query(query, jsonList(guids))
+	if err != nil {
+		return err
+	}
+	defer closeRowsBOF(rows)
+
+	for rows.Next() {
+		var guid, tag string
+		if err := rows.Scan(&guid, &tag); err != nil {
+			return err
+		}
+		if i, ok := index[guid]; ok {
+			articles[i].Tags = append(articles[i].Tags, tag)
+		}
+	}
+	return nil
+}
+
+// PruneArticles deletes articles published before the given time, along with their tags
+// and delivery records, and returns the number of articles deleted. Reports keep their
+// history, but no longer list the deleted articles. Clusters whose representative was
+// deleted are taken over by their oldest remaining member.
+func (d *DB) PruneArticles(before time.Time) (int64, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec("DELETE FROM articles WHERE published_date < ?", before)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	for _, query := range []string{
+		"DELETE FROM article_tags WHERE guid NOT IN (SELECT guid FROM articles)",
+		"DELETE FROM article_deliveries WHERE guid NOT IN (SELECT guid FROM articles)",
+		"DELETE FROM score_history WHERE guid NOT IN (SELECT guid FROM articles)",
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := reassignClusters(tx); err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // Close closes the database connection.
 func (d *DB) Close() {
 	if d.conn != nil {
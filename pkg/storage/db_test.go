@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDB returns a new, empty database, closed at the end of the test.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// manyGUIDs returns more GUIDs than SQLite allows parameters in one statement, ending with
+// the given ones.
+func manyGUIDs(guids ...string) []string {
+	var list []string
+	for i := 0; i < 40000; i++ {
+		list = append(list, fmt.Sprintf("missing-%d", i))
+	}
+	return append(list, guids...)
+}
+
+func TestLongGUIDLists(t *testing.T) {
+	db := newTestDB(t)
+	published := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	articles := []Article{
+		{GUID: "a", Title: "Z80 computer", PublishedDate: published, ClusterID: "a"},
+		{GUID: "a-copy", Title: "Z80 computer, again", PublishedDate: published.Add(time.Hour), ClusterID: "a"},
+		{GUID: "b", Title: "Library hours", PublishedDate: published},
+	}
+	for _, art := range articles {
+		if err := db.SaveArticle(art); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SetArticleTags("a", []string{"z80", "retro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := db.getArticlesByGUID(manyGUIDs("a", "b"))
+	if err != nil {
+		t.Fatalf("getArticlesByGUID: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("getArticlesByGUID found %d articles, want 2", len(found))
+	}
+
+	page := make([]Article, 0, 40001)
+	for _, guid := range manyGUIDs("a") {
+		page = append(page, Article{GUID: guid})
+	}
+	if err := db.loadTags(page); err != nil {
+		t.Fatalf("loadTags: %v", err)
+	}
+	if got := page[len(page)-1].Tags; len(got) != 2 {
+		t.Errorf("tags of a = %v, want 2 tags", got)
+	}
+	if err := db.LoadDuplicates(page); err != nil {
+		t.Fatalf("LoadDuplicates: %v", err)
+	}
+	if got := page[len(page)-1].Duplicates; len(got) != 1 || got[0].GUID != "a-copy" {
+		t.Errorf("duplicates of a = %v, want a-copy", got)
+	}
+
+	if err := db.MarkArticlesReported(manyGUIDs("a")); err != nil {
+		t.Fatalf("MarkArticlesReported: %v", err)
+	}
+	for guid, want := range map[string]bool{"a": true, "a-copy": true, "b": false} {
+		art, err := db.GetArticle(guid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if art.Reported != want {
+			t.Errorf("%s reported = %v, want %v", guid, art.Reported, want)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"z80", "z80"},
+		{"Z80", "z80"},
+		{"  retro  ", "retro"},
+		{"Retro Computing", "retro-computing"},
+		{"retro \t computing", "retro-computing"},
+		{`"z80"`, "z80"},
+		{"**z80**", "z80"},
+		{"#z80.", "z80"},
+		{"[z80]", "z80"},
+		{"(retro computing)", "retro-computing"},
+		{"c++", "c++"},
+		{"ham-radio", "ham-radio"},
+		{"", ""},
+		{"  ", ""},
+		{"**", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeTag(tt.tag); got != tt.want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// createDeliveriesSQL creates the tables recording which articles were delivered where.
+// article_deliveries holds one row per article successfully delivered to a destination, and
+// delivery_log holds the outcome of every delivery attempt.
+const createDeliveriesSQL = `CREATE TABLE IF NOT EXISTS article_deliveries (
+	guid TEXT NOT NULL,
+	destination TEXT NOT NULL,
+	delivered_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (guid, destination)
+);
+CREATE TABLE IF NOT EXISTS delivery_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	report_id INTEGER DEFAULT 0,
+	destination TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT DEFAULT '',
+	articles INTEGER DEFAULT 0,
+	attempted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`
+
+// Delivery statuses.
+const (
+	DELIVERY_OK     = "ok"
+	DELIVERY_FAILED = "failed"
+)
+
+// Delivery is the outcome of one attempt to deliver articles to a destination.
+type Delivery struct {
+	ID          int64
+	ReportID    int64
+	Destination string
+	Status      string
+	Error       string
+	Articles    int
+	AttemptedAt time.Time
+}
+
+// RecordDelivery logs an attempt to deliver the given articles of a report to a destination.
+// If deliveryErr is nil, the articles are also recorded as delivered there.
+func (d *DB) RecordDelivery(reportID int64, destination string, guids []string, deliveryErr error) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	status, message := DELIVERY_OK, ""
+	if deliveryErr != nil {
+		status, message = DELIVERY_FAILED, deliveryErr.Error()
+	}
+	_, err = tx.Exec("INSERT INTO delivery_log (report_id, destination, status, error, articles, attempted_at) VALUES (?, ?, ?, ?, ?, ?)",
+		reportID, destination, status, message, len(guids), time.Now())
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if deliveryErr == nil {
+		for _, guid := range guids {
+			_, err := tx.Exec("INSERT OR REPLACE INTO article_deliveries (guid, destination, delivered_at) VALUES (?, ?, ?)",
+				guid, destination, time.Now())
+			if err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// SkipDelivery records that the articles need not be delivered to the destination, e.g.
+// because they are below its threshold, so that they are treated as delivered there.
+func (d *DB) SkipDelivery(destination string, guids []string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+	for _, guid := range guids {
+		_, err := tx.Exec("INSERT OR IGNORE INTO article_deliveries (guid, destination, delivered_at) VALUES (?, ?, ?)",
+			guid, destination, time.Now())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDeliveredGUIDs returns the subset of the given articles that have been delivered to
+// the destination.
+func (d *DB) GetDeliveredGUIDs(destination string, guids []string) (map[string]bool, error) {
+	delivered := make(map[string]bool)
+	if len(guids) == 0 {
+		return delivered, nil
+	}
+
+	query := "SELECT guid FROM article_deliveries WHERE destination = ? AND guid " + inList
+	rows, err := d.conn.Query(query, destination, jsonList(guids))
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, err
+		}
+		delivered[guid] = true
+	}
+	return delivered, rows.Err()
+}
+
+// ListDeliveries returns the most recent delivery attempts, up to the specified limit.
+func (d *DB) ListDeliveries(limit int) ([]Delivery, error) {
+	rows, err := d.conn.Query(`SELECT id, COALESCE(report_id, 0), destination, status, COALESCE(error, ''), articles, attempted_at
+              FROM delivery_log ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	return scanDeliveries(rows)
+}
+
+// GetReportDeliveries returns the delivery attempts for a report, oldest first.
+func (d *DB) GetReportDeliveries(reportID int64) ([]Delivery, error) {
+	rows, err := d.conn.Query(`SELECT id, COALESCE(report_id, 0), destination, status, COALESCE(error, ''), articles, attempted_at
+              FROM delivery_log WHERE report_id = ? ORDER BY id`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	return scanDeliveries(rows)
+}
+
+// scanDeliveries reads all rows produced by a delivery_log query.
+func scanDeliveries(rows *sql.Rows) ([]Delivery, error) {
+	var deliveries []Delivery
+	for rows.Next() {
+		var del Delivery
+		if err := rows.Scan(&del.ID, &del.ReportID, &del.Destination, &del.Status, &del.Error, &del.Articles, &del.AttemptedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, del)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// createFeedsSQL creates the table of feeds that articles have been fetched from.
+const createFeedsSQL = `CREATE TABLE IF NOT EXISTS feeds (
+	url TEXT PRIMARY KEY,
+	title TEXT DEFAULT '',
+	last_fetched DATETIME
+);`
+
+// Feed is a feed that has been fetched or subscribed to.
+type Feed struct {
+	URL   string
+	Title string
+
+	// LastFetched is zero if the feed has not been fetched yet.
+	LastFetched time.Time
+
+	// Subscribed is true if the feed was added through the API, and is fetched along with
+	// the configured feed.
+	Subscribed bool
+	Articles   int
+}
+
+// SaveFeed records a feed's title and the time it was last fetched.
+func (d *DB) SaveFeed(url, title string, fetched time.Time) error {
+	query := `INSERT INTO feeds (url, title, last_fetched) VALUES (?, ?, ?)
+              ON CONFLICT(url) DO UPDATE SET title = excluded.title, last_fetched = excluded.last_fetched`
+	_, err := d.conn.Exec(query, url, title, fetched)
+	return err
+}
+
+// GetFeedNames returns a map of feed URL to feed title, for every feed with a title.
+func (d *DB) GetFeedNames() (map[string]string, error) {
+	rows, err := d.conn.Query("SELECT url, title FROM feeds WHERE title IS NOT NULL AND title != ''")
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var url, title string
+		if err := rows.Scan(&url, &title); err != nil {
+			return nil, err
+		}
+		names[url] = title
+	}
+	return names, rows.Err()
+}
+
+// feedColumns are the columns read by scanFeed, including the number of articles fetched
+// from the feed.
+const feedColumns = `url, COALESCE(title, ''), last_fetched, COALESCE(subscribed, 0),
+	(SELECT COUNT(*) FROM articles WHERE articles.feed_url = feeds.url)`
+
+// scanFeed reads a row selecting feedColumns.
+func scanFeed(row interface{ Scan(...any) error }, feed *Feed) error {
+	var fetched sql.NullTime
+	if err := row.Scan(&feed.URL, &feed.Title, &fetched, &feed.Subscribed, &feed.Articles); err != nil {
+		return err
+	}
+	feed.LastFetched = fetched.Time
+	return nil
+}
+
+// ListFeeds returns every feed, with the number of articles fetched from it.
+func (d *DB) ListFeeds() ([]Feed, error) {
+	rows, err := d.conn.Query("SELECT " + feedColumns + " FROM feeds ORDER BY url")
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var feeds []Feed
+	for rows.Next() {
+		var feed Feed
+		if err := scanFeed(rows, &feed); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, rows.Err()
+}
+
+// GetFeed returns the feed with the given URL, or nil if there is no such feed.
+func (d *DB) GetFeed(url string) (*Feed, error) {
+	var feed Feed
+	err := scanFeed(d.conn.QueryRow("SELECT "+feedColumns+" FROM feeds WHERE url = ?", url), &feed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+// GetSubscribedFeeds returns the URLs of the feeds subscribed to.
+func (d *DB) GetSubscribedFeeds() ([]string, error) {
+	rows, err := d.conn.Query("SELECT url FROM feeds WHERE subscribed = 1 ORDER BY url")
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+// SetFeedSubscribed subscribes to the feed, adding it if it is new, or unsubscribes from it.
+// Unsubscribing keeps the feed's title and articles. It returns false if unsubscribing from
+// a feed that is not known.
+func (d *DB) SetFeedSubscribed(url string, subscribed bool) (bool, error) {
+	if subscribed {
+		query := `INSERT INTO feeds (url, subscribed) VALUES (?, 1)
+                  ON CONFLICT(url) DO UPDATE SET subscribed = 1`
+		_, err := d.conn.Exec(query, url)
+		return err == nil, err
+	}
+
+	res, err := d.conn.Exec("UPDATE feeds SET subscribed = 0 WHERE url = ?", url)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetFeed(t *testing.T) {
+	db := newTestDB(t)
+	fetched := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := db.SaveFeed("https://example.com/feed", "Example", fetched); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveArticle(Article{GUID: "a", FeedURL: "https://example.com/feed", PublishedDate: fetched}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetFeedSubscribed("https://example.com/new", true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want *Feed
+	}{
+		{"https://example.com/feed", &Feed{URL: "https://example.com/feed", Title: "Example", LastFetched: fetched, Articles: 1}},
+		{"https://example.com/new", &Feed{URL: "https://example.com/new", Subscribed: true}},
+		{"https://example.com/unknown", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, err := db.GetFeed(tt.url)
+			if err != nil {
+				t.Fatalf("GetFeed(%q): %v", tt.url, err)
+			}
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("GetFeed(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+			if got == nil {
+				return
+			}
+			if got.URL != tt.want.URL || got.Title != tt.want.Title || !got.LastFetched.Equal(tt.want.LastFetched) ||
+				got.Subscribed != tt.want.Subscribed || got.Articles != tt.want.Articles {
+				t.Errorf("GetFeed(%q) = %+v, want %+v", tt.url, *got, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// createPromptsSQL creates the table of prompt templates saved from the web interface. At
+// most one is active; if one is, articles are scored with it rather than the configured
+// prompt.
+const createPromptsSQL = `CREATE TABLE IF NOT EXISTS prompts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	template TEXT NOT NULL,
+	active BOOLEAN DEFAULT 0
+);`
+
+// PromptVersion is a saved prompt template.
+type PromptVersion struct {
+	ID        int64
+	CreatedAt time.Time
+	Template  string
+	Active    bool
+}
+
+// SavePrompt saves the template as a new version of the prompt, and makes it the active one.
+func (d *DB) SavePrompt(template string) (*PromptVersion, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("UPDATE prompts SET active = 0 WHERE active = 1"); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	p := &PromptVersion{CreatedAt: time.Now(), Template: template, Active: true}
+	res, err := tx.Exec("INSERT INTO prompts (created_at, template, active) VALUES (?, ?, 1)", p.CreatedAt, p.Template)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if p.ID, err = res.LastInsertId(); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return p, tx.Commit()
+}
+
+// DeactivatePrompts makes none of the saved prompts active, so that articles are scored with
+// the configured prompt again.
+func (d *DB) DeactivatePrompts() error {
+	_, err := d.conn.Exec("UPDATE prompts SET active = 0 WHERE active = 1")
+	return err
+}
+
+// GetActivePrompt returns the active prompt, or nil if none is active.
+func (d *DB) GetActivePrompt() (*PromptVersion, error) {
+	return d.getPrompt("SELECT id, created_at, template, active FROM prompts WHERE active = 1 ORDER BY id DESC LIMIT 1")
+}
+
+// GetPrompt returns the saved prompt with the given ID, or nil if there is no such prompt.
+func (d *DB) GetPrompt(id int64) (*PromptVersion, error) {
+	return d.getPrompt("SELECT id, created_at, template, active FROM prompts WHERE id = ?", id)
+}
+
+// getPrompt returns the prompt selected by the query, or nil if it selects none.
+func (d *DB) getPrompt(query string, args ...interface{}) (*PromptVersion, error) {
+	var p PromptVersion
+	err := d.conn.QueryRow(query, args...).Scan(&p.ID, &p.CreatedAt, &p.Template, &p.Active)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPrompts returns the saved prompts, most recent first.
+func (d *DB) ListPrompts() ([]PromptVersion, error) {
+	rows, err := d.conn.Query("SELECT id, created_at, template, active FROM prompts ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var prompts []PromptVersion
+	for rows.Next() {
+		var p PromptVersion
+		if err := rows.Scan(&p.ID, &p.CreatedAt, &p.Template, &p.Active); err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, p)
+	}
+	return prompts, rows.Err()
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MAX_QUERY_LIMIT is the most articles QueryArticles returns at once.
+const MAX_QUERY_LIMIT = 500
+
+// ErrBadCursor is returned by QueryArticles for a cursor it did not produce.
+var ErrBadCursor = errors.New("invalid cursor")
+
+// Orders that QueryArticles can sort articles in.
+const (
+	SORT_DATE  = "date"
+	SORT_SCORE = "score"
+	SORT_TITLE = "title"
+	SORT_FEED  = "feed"
+)
+
+// SORTS lists the supported sort orders.
+var SORTS = []string{SORT_DATE, SORT_SCORE, SORT_TITLE, SORT_FEED}
+
+// sortExpressions maps each sort order to the expression it sorts by. None of them is ever
+// NULL, so that the cursor can always be compared with them: articles without a date sort
+// before the oldest, and unscored articles as a score of -1, below any real score.
+var sortExpressions = map[string]string{
+	SORT_DATE:  "COALESCE(published_date, '')",
+	SORT_SCORE: "CASE WHEN " + scored + " THEN CAST(score AS INTEGER) ELSE -1 END",
+	SORT_TITLE: "LOWER(COALESCE(title, ''))",
+	SORT_FEED:  "COALESCE(feed_url, '')",
+}
+
+// scored is a condition matching articles that have been given a score.
+const scored = `(score IS NOT NULL AND score != '' AND score != 'N/A')`
+
+// ArticleQuery selects a page of articles. Empty fields, zero times and nil pointers match
+// all articles.
+type ArticleQuery struct {
+	// Search matches articles containing every word of the text in their title, description,
+	// content or analysis, as SearchArticles does.
+	Search   string
+	Tag      string
+	FeedURL  string
+	Model    string
+	Reported *bool
+	Read     *bool
+	Starred  *bool
+	Scored   *bool
+
+	// MinScore and MaxScore only match scored articles.
+	MinScore *int
+	MaxScore *int
+
+	// PublishedAfter is inclusive, and PublishedBefore exclusive.
+	PublishedAfter  time.Time
+	PublishedBefore time.Time
+
+	// Sort is one of SORTS, SORT_DATE if empty. Ascending reverses the default, descending,
+	// order.
+	Sort      string
+	Ascending bool
+
+	// Cursor continues from where the previous page, which returned it, left off. The rest
+	// of the query must be the same as for that page.
+	Cursor string
+	Limit  int
+}
+
+// ValidSort returns true if the sort order is supported. An empty sort order is valid.
+func ValidSort(sort string) bool {
+	return sort == "" || slices.Contains(SORTS, sort)
+}
+
+// QueryArticles returns the page of articles selected by the query, with their tags, and the
+// cursor for the next page, which is empty on the last page.
+func (d *DB) QueryArticles(q ArticleQuery) ([]Article, string, error) {
+	where := []string{"1=1"}
+	var args []interface{}
+
+	if match := SearchQuery(q.Search); match != "" {
+		where = append(where, "guid IN (SELECT guid FROM article_search_ids WHERE id IN (SELECT rowid FROM articles_fts WHERE articles_fts MATCH ?))")
+		args = append(args, match)
+	}
+	if q.Tag != "" {
+		where = append(where, "guid IN (SELECT guid FROM article_tags WHERE tag = ?)")
+		args = append(args, q.Tag)
+	}
+	if q.FeedURL != "" {
+		where = append(where, "feed_url = ?")
+		args = append(args, q.FeedURL)
+	}
+	if q.Model != "" {
+		where = append(where, "model = ?")
+		args = append(args, q.Model)
+	}
+	flags := []struct {
+		column string
+		value  *bool
+	}{
+		{"reported", q.Reported},
+		{"read", q.Read},
+		{"starred", q.Starred},
+	}
+	for _, flag := range flags {
+		if flag.value != nil {
+			where = append(where, "COALESCE("+flag.column+", 0) = ?")
+			args = append(args, *flag.value)
+		}
+	}
+	if q.Scored != nil {
+		if *q.Scored {
+			where = append(where, scored)
+		} else {
+			where = append(where, "NOT "+scored)
+		}
+	}
+	if q.MinScore != nil {
+		where = append(where, scored+" AND CAST(score AS INTEGER) >= ?")
+		args = append(args, *q.MinScore)
+	}
+	if q.MaxScore != nil {
+		where = append(where, scored+" AND CAST(score AS INTEGER) <= ?")
+		args = append(args, *q.MaxScore)
+	}
+	if !q.PublishedAfter.IsZero() {
+		where = append(where, "published_date >= ?")
+		args = append(args, q.PublishedAfter)
+	}
+	if !q.PublishedBefore.IsZero() {
+		where = append(where, "published_date < ?")
+		args = append(args, q.PublishedBefore)
+	}
+
+	if !ValidSort(q.Sort) {
+		return nil, "", errors.New("invalid sort order " + q.Sort)
+	}
+	expr := sortExpressions[q.Sort]
+	if expr == "" {
+		expr = sortExpressions[SORT_DATE]
+	}
+	direction, compare := "DESC", "<"
+	if q.Ascending {
+		direction, compare = "ASC", ">"
+	}
+
+	if q.Cursor != "" {
+		key, guid, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, "", err
+		}
+		// The GUID breaks ties between articles with the same sort key.
+		where = append(where, "("+expr+", guid) "+compare+" (?, ?)")
+		args = append(args, key, guid)
+	}
+
+	limit := q.Limit
+	if limit <= 0 || limit > MAX_QUERY_LIMIT {
+		limit = MAX_QUERY_LIMIT
+	}
+
+	// One more than the limit is fetched, to tell whether there is a next page.
+	query := "SELECT " + articleColumns + ", " + expr + " FROM articles WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY " + expr + " " + direction + ", guid " + direction + " LIMIT ?"
+	rows, err := d.conn.Query(query, append(args, limit+1)...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer closeRowsBOF(rows)
+
+	var articles []Article
+	var keys []any
+	for rows.Next() {
+		var art Article
+		var key any
+		if err := scanArticle(rows, &art, &key); err != nil {
+			return nil, "", err
+		}
+		articles = append(articles, art)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(articles) > limit {
+		articles = articles[:limit]
+		if next, err = encodeCursor(keys[limit-1], articles[limit-1].GUID); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := d.loadTags(articles); err != nil {
+		return nil, "", err
+	}
+	return articles, next, nil
+}
+
+// cursor is the position of the last article of a page: its sort key and GUID.
+type cursor struct {
+	Key  any    `json:"k"`
+	GUID string `json:"g"`
+}
+
+// encodeCursor returns the cursor continuing after the article with the sort key and GUID.
+func encodeCursor(key any, guid string) (string, error) {
+	if b, ok := key.([]byte); ok {
+		key = string(b)
+	}
+	data, err := json.Marshal(cursor{Key: key, GUID: guid})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor returns the sort key and GUID of a cursor made by encodeCursor for the sort
+// order, or ErrBadCursor. The key is an integer when sorting by score, and a string otherwise.
+func decodeCursor(s string, sort string) (any, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, "", ErrBadCursor
+	}
+	var c cursor
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || c.GUID == "" {
+		return nil, "", ErrBadCursor
+	}
+
+	switch key := c.Key.(type) {
+	case json.Number:
+		n, err := key.Int64()
+		if err != nil || sort != SORT_SCORE {
+			return nil, "", ErrBadCursor
+		}
+		return n, c.GUID, nil
+	case string:
+		if sort == SORT_SCORE {
+			return nil, "", ErrBadCursor
+		}
+		return key, c.GUID, nil
+	}
+	return nil, "", ErrBadCursor
+}
+
+// ListModels returns the models that have scored articles, most used first.
+func (d *DB) ListModels() ([]string, error) {
+	rows, err := d.conn.Query("SELECT model FROM articles WHERE model IS NOT NULL AND model != '' GROUP BY model ORDER BY COUNT(*) DESC, model")
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var models []string
+	for rows.Next() {
+		var model string
+		if err := rows.Scan(&model); err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+	return models, rows.Err()
+}
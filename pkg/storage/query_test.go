@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		sort string
+		key  any
+	}{
+		{"date", SORT_DATE, "2024-01-01 10:00:00"},
+		{"missing date", SORT_DATE, ""},
+		{"score", SORT_SCORE, int64(87)},
+		{"unscored", SORT_SCORE, int64(-1)},
+		{"title", SORT_TITLE, "a z80 computer"},
+		{"feed as bytes", SORT_FEED, []byte("https://example.com/feed")},
+		{"default sort", "", "2024-01-01 10:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := encodeCursor(tt.key, "guid-1")
+			if err != nil {
+				t.Fatalf("encodeCursor(%v): %v", tt.key, err)
+			}
+			key, guid, err := decodeCursor(c, tt.sort)
+			if err != nil {
+				t.Fatalf("decodeCursor(%q): %v", c, err)
+			}
+			want := tt.key
+			if b, ok := want.([]byte); ok {
+				want = string(b)
+			}
+			if key != want || guid != "guid-1" {
+				t.Errorf("decodeCursor(%q) = %v, %q, want %v, %q", c, key, guid, want, "guid-1")
+			}
+		})
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+	}{
+		{"not base64", "!!!", SORT_DATE},
+		{"not JSON", encode("a1"), SORT_DATE},
+		{"no GUID", encode(`{"k":"2024-01-01"}`), SORT_DATE},
+		{"empty GUID", encode(`{"k":"2024-01-01","g":""}`), SORT_DATE},
+		{"no key", encode(`{"g":"a1"}`), SORT_DATE},
+		{"null key", encode(`{"k":null,"g":"a1"}`), SORT_DATE},
+		{"number for a date", encode(`{"k":5,"g":"a1"}`), SORT_DATE},
+		{"string for a score", encode(`{"k":"5","g":"a1"}`), SORT_SCORE},
+		{"fractional score", encode(`{"k":5.5,"g":"a1"}`), SORT_SCORE},
+		{"object key", encode(`{"k":{},"g":"a1"}`), SORT_TITLE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor, tt.sort); !errors.Is(err, ErrBadCursor) {
+				t.Errorf("decodeCursor(%q, %q) error = %v, want %v", tt.cursor, tt.sort, err, ErrBadCursor)
+			}
+		})
+	}
+}
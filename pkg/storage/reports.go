@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// createReportsSQL creates the tables recording each generated report. report_articles links
+// a report to the articles it included; articles folded into a story have the story's
+// headline as their parent.
+const createReportsSQL = `CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	title TEXT NOT NULL,
+	format TEXT DEFAULT '',
+	threshold INTEGER DEFAULT 0,
+	age_days INTEGER DEFAULT 0,
+	by_tag BOOLEAN DEFAULT 0,
+	destinations TEXT DEFAULT '',
+	status TEXT NOT NULL,
+	error TEXT DEFAULT '',
+	html TEXT DEFAULT '',
+	group_by TEXT DEFAULT '',
+	sort_order TEXT DEFAULT '',
+	omitted INTEGER DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS report_articles (
+	report_id INTEGER NOT NULL,
+	guid TEXT NOT NULL,
+	parent_guid TEXT DEFAULT '',
+	position INTEGER DEFAULT 0,
+	PRIMARY KEY (report_id, guid)
+);
+CREATE INDEX IF NOT EXISTS idx_report_articles_guid ON report_articles (guid);`
+
+// Report statuses.
+const (
+	REPORT_PENDING = "pending"
+	REPORT_SENT    = "sent"
+	REPORT_PARTIAL = "partial"
+	REPORT_FAILED  = "failed"
+)
+
+// Report is a generated report: the articles it included, where it was sent, and whether
+// that worked. GroupBy and SortOrder record how the articles were laid out, and Omitted how
+// many were left out to keep the report short. HTML holds the rendered report; it is only
+// stored, never loaded with the rest, since it can be large. Use GetReportHTML to fetch it.
+type Report struct {
+	ID           int64
+	CreatedAt    time.Time
+	Title        string
+	Format       string
+	Threshold    int
+	AgeDays      int
+	GroupBy      string
+	SortOrder    string
+	Omitted      int
+	Destinations []string
+	Status       string
+	Error        string
+	ArticleCount int
+	HTML         string
+}
+
+// CreateReport records a new pending report and links it to its articles, including those
+// folded into a story. It sets the report's ID.
+func (d *DB) CreateReport(r *Report, articles []Article) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	r.CreatedAt = time.Now()
+	r.Status = REPORT_PENDING
+	result, err := tx.Exec(`INSERT INTO reports (created_at, title, format, threshold, age_days, by_tag, group_by, sort_order, omitted,
+		destinations, status, html) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.CreatedAt, r.Title, r.Format, r.Threshold, r.AgeDays, r.GroupBy == "tag", r.GroupBy, r.SortOrder, r.Omitted,
+		strings.Join(r.Destinations, ","), r.Status, r.HTML)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	r.ID, err = result.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	position := 0
+	insert := func(guid, parent string) error {
+		position++
+		_, err := tx.Exec("INSERT OR IGNORE INTO report_articles (report_id, guid, parent_guid, position) VALUES (?, ?, ?, ?)",
+			r.ID, guid, parent, position)
+		return err
+	}
+	for _, art := range articles {
+		if err := insert(art.GUID, ""); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		for _, related := range art.Related {
+			if err := insert(related.GUID, art.GUID); err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+	}
+	r.ArticleCount = position
+	return tx.Commit()
+}
+
+// UpdateReportStatus records the outcome of delivering a report.
+func (d *DB) UpdateReportStatus(id int64, status, message string) error {
+	_, err := d.conn.Exec("UPDATE reports SET status = ?, error = ? WHERE id = ?", status, message, id)
+	return err
+}
+
+// reportColumns is the list of columns selected by every query returning reports. It must
+// match the order of the fields in scanReports. Reports from before group_by existed only
+// recorded whether they were grouped by tag.
+const reportColumns = `id, created_at, title, COALESCE(format, ''), threshold, age_days,
+	CASE WHEN COALESCE(group_by, '') = '' AND by_tag THEN 'tag' ELSE COALESCE(group_by, '') END,
+	COALESCE(sort_order, ''), COALESCE(omitted, 0), COALESCE(destinations, ''), status,
+	COALESCE(error, ''), (SELECT COUNT(*) FROM report_articles WHERE report_id = reports.id)`
+
+// scanReports reads all rows produced by a query selecting reportColumns.
+func scanReports(rows *sql.Rows) ([]Report, error) {
+	var reports []Report
+	for rows.Next() {
+		var r Report
+		var destinations string
+		err := rows.Scan(&r.ID, &r.CreatedAt, &r.Title, &r.Format, &r.Threshold, &r.AgeDays, &r.GroupBy, &r.SortOrder, &r.Omitted,
+			&destinations, &r.Status, &r.Error, &r.ArticleCount)
+		if err != nil {
+			return nil, err
+		}
+		if destinations != "" {
+			r.Destinations = strings.Split(destinations, ",")
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// GetReport returns the report with the given ID, or nil if there is no such report.
+func (d *DB) GetReport(id int64) (*Report, error) {
+	rows, err := d.conn.Query("SELECT "+reportColumns+" FROM reports WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	reports, err := scanReports(rows)
+	if err != nil || len(reports) == 0 {
+		return nil, err
+	}
+	return &reports[0], nil
+}
+
+// GetReportHTML returns the rendered HTML of a report, or an empty string if it was not stored.
+func (d *DB) GetReportHTML(id int64) (string, error) {
+	var html string
+	err := d.conn.QueryRow("SELECT COALESCE(html, '') FROM reports WHERE id = ?", id).Scan(&html)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return html, err
+}
+
+// GetArticleReports returns the reports that included the article, most recent first.
+func (d *DB) GetArticleReports(guid string) ([]Report, error) {
+	rows, err := d.conn.Query(`SELECT `+reportColumns+` FROM reports
+              WHERE id IN (SELECT report_id FROM report_articles WHERE guid = ?) ORDER BY id DESC`, guid)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	return scanReports(rows)
+}
+
+// ListReports returns the most recent reports, up to the specified limit.
+func (d *DB) ListReports(limit int) ([]Report, error) {
+	rows, err := d.conn.Query("SELECT "+reportColumns+" FROM reports ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	return scanReports(rows)
+}
+
+// GetReportArticles returns the articles included in a report, in their original order,
+// with articles that were folded into a story back in their headline's Related field.
+func (d *DB) GetReportArticles(id int64) ([]Article, error) {
+	rows, err := d.conn.Query("SELECT guid, COALESCE(parent_guid, '') FROM report_articles WHERE report_id = ? ORDER BY position", id)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var guids []string
+	parents := make(map[string]string)
+	for rows.Next() {
+		var guid, parent string
+		if err := rows.Scan(&guid, &parent); err != nil {
+			return nil, err
+		}
+		guids = append(guids, guid)
+		parents[guid] = parent
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	byGUID, err := d.getArticlesByGUID(guids)
+	if err != nil {
+		return nil, err
+	}
+
+	var articles []Article
+	index := make(map[string]int)
+	for _, guid := range guids {
+		art, ok := byGUID[guid]
+		if !ok {
+			// The article has since been removed from the database.
+			continue
+		}
+		if i, ok := index[parents[guid]]; ok {
+			articles[i].Related = append(articles[i].Related, art)
+			continue
+		}
+		index[guid] = len(articles)
+		articles = append(articles, art)
+	}
+
+	if err := d.loadTags(articles); err != nil {
+		return nil, err
+	}
+	return articles, d.LoadDuplicates(articles)
+}
+
+// GetArticle returns the article with the given GUID, with its tags and duplicates, or nil
+// if there is no such article.
+func (d *DB) GetArticle(guid string) (*Article, error) {
+	byGUID, err := d.getArticlesByGUID([]string{guid})
+	if err != nil {
+		return nil, err
+	}
+	art, ok := byGUID[guid]
+	if !ok {
+		return nil, nil
+	}
+
+	articles := []Article{art}
+	if err := d.loadTags(articles); err != nil {
+		return nil, err
+	}
+	if err := d.LoadDuplicates(articles); err != nil {
+		return nil, err
+	}
+	return &articles[0], nil
+}
+
+// getArticlesByGUID returns the articles with the given GUIDs, keyed by GUID.
+func (d *DB) getArticlesByGUID(guids []string) (map[string]Article, error) {
+	articles := make(map[string]Article, len(guids))
+	if len(guids) == 0 {
+		return articles, nil
+	}
+
+	query := "SELECT " + articleColumns + " FROM articles WHERE guid " + inList
+	rows, err := d.conn.Query(query, jsonList(guids))
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	found, err := scanArticles(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, art := range found {
+		articles[art.GUID] = art
+	}
+	return articles, nil
+}
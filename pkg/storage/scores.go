@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"time"
+)
+
+// createScoresSQL creates the table recording every time an article was scored, with the
+// prompt that was sent to the model.
+const createScoresSQL = `CREATE TABLE IF NOT EXISTS score_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	guid TEXT NOT NULL,
+	scored_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	score TEXT DEFAULT '',
+	analysis TEXT DEFAULT '',
+	model TEXT DEFAULT '',
+	prompt TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_score_history_guid ON score_history (guid);`
+
+// ScoreRecord is one scoring of an article: what the model was asked, and what it answered.
+type ScoreRecord struct {
+	ID       int64
+	GUID     string
+	ScoredAt time.Time
+	Score    string
+	Analysis string
+	Model    string
+	Prompt   string
+}
+
+// UpdateArticleScore updates the score and analysis for a given article GUID, and records
+// them in the article's scoring history along with the prompt that produced them.
+func (d *DB) UpdateArticleScore(guid, score, analysis, model, prompt string) error {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE articles SET score = ?, analysis = ?, model = ? WHERE guid = ?`
+	if _, err := tx.Exec(query, score, analysis, model, guid); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	query = `INSERT INTO score_history (guid, scored_at, score, analysis, model, prompt) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.Exec(query, guid, time.Now(), score, analysis, model, prompt); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetScoreHistory returns the times the article has been scored, most recent first.
+func (d *DB) GetScoreHistory(guid string) ([]ScoreRecord, error) {
+	query := `SELECT id, guid, scored_at, COALESCE(score, ''), COALESCE(analysis, ''), COALESCE(model, ''), COALESCE(prompt, '')
+              FROM score_history WHERE guid = ? ORDER BY id DESC`
+	rows, err := d.conn.Query(query, guid)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var records []ScoreRecord
+	for rows.Next() {
+		var rec ScoreRecord
+		if err := rows.Scan(&rec.ID, &rec.GUID, &rec.ScoredAt, &rec.Score, &rec.Analysis, &rec.Model, &rec.Prompt); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// DeleteArticle deletes the article with the given GUID, along with its tags, deliveries and
+// scoring history, and returns false if there is no such article. If it represented a cluster,
+// the oldest of its duplicates takes its place. It is fetched again if it is still in its feed.
+func (d *DB) DeleteArticle(guid string) (bool, error) {
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.Exec("DELETE FROM articles WHERE guid = ?", guid)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	for _, query := range []string{
+		"DELETE FROM article_tags WHERE guid = ?",
+		"DELETE FROM article_deliveries WHERE guid = ?",
+		"DELETE FROM score_history WHERE guid = ?",
+	} {
+		if _, err := tx.Exec(query, guid); err != nil {
+			_ = tx.Rollback()
+			return false, err
+		}
+	}
+	if err := reassignClusters(tx); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// createSearchIDsSQL creates the table giving every article a fixed integer ID for the
+// full-text index. Articles are keyed by GUID, and their implicit rowids may change on VACUUM,
+// but an INTEGER PRIMARY KEY never does.
+const createSearchIDsSQL = `CREATE TABLE IF NOT EXISTS article_search_ids (
+	id INTEGER PRIMARY KEY,
+	guid TEXT NOT NULL UNIQUE
+);
+CREATE VIEW IF NOT EXISTS article_search AS
+	SELECT s.id, a.title, a.translated_title, a.description, a.content, a.analysis
+	FROM article_search_ids s JOIN articles a ON a.guid = s.guid;`
+
+// createSearchSQL creates the full-text index of articles. It is an external content table,
+// holding only the index and reading the text through the article_search view by ID, and is
+// kept in step with articles by the triggers.
+const createSearchSQL = `CREATE VIRTUAL TABLE articles_fts USING fts5(
+	title, translated_title, description, content, analysis,
+	content='article_search', content_rowid='id', tokenize='porter unicode61 remove_diacritics 2'
+);`
+
+// searchColumns are the columns in the full-text index, in order.
+const searchColumns = `title, translated_title, description, content, analysis`
+
+// searchID looks up the search ID of the new or old article in a trigger.
+const searchID = `(SELECT id FROM article_search_ids WHERE guid = %s.guid)`
+
+// createSearchTriggersSQL keeps the search IDs and the full-text index in step with the
+// articles.
+var createSearchTriggersSQL = `CREATE TRIGGER IF NOT EXISTS article_search_insert AFTER INSERT ON articles BEGIN
+	INSERT OR IGNORE INTO article_search_ids (guid) VALUES (new.guid);
+	INSERT INTO articles_fts (rowid, ` + searchColumns + `)
+	VALUES (` + fmt.Sprintf(searchID, "new") + `, new.title, new.translated_title, new.description, new.content, new.analysis);
+END;
+CREATE TRIGGER IF NOT EXISTS article_search_delete AFTER DELETE ON articles BEGIN
+	INSERT INTO articles_fts (articles_fts, rowid, ` + searchColumns + `)
+	VALUES ('delete', ` + fmt.Sprintf(searchID, "old") + `, old.title, old.translated_title, old.description, old.content, old.analysis);
+	DELETE FROM article_search_ids WHERE guid = old.guid;
+END;
+CREATE TRIGGER IF NOT EXISTS article_search_update AFTER UPDATE OF ` + searchColumns + ` ON articles BEGIN
+	INSERT INTO articles_fts (articles_fts, rowid, ` + searchColumns + `)
+	VALUES ('delete', ` + fmt.Sprintf(searchID, "old") + `, old.title, old.translated_title, old.description, old.content, old.analysis);
+	INSERT INTO articles_fts (rowid, ` + searchColumns + `)
+	VALUES (` + fmt.Sprintf(searchID, "new") + `, new.title, new.translated_title, new.description, new.content, new.analysis);
+END;`
+
+// dropRowidSearchSQL drops the first version of the full-text index, which was keyed by the
+// articles' rowids.
+const dropRowidSearchSQL = `DROP TRIGGER IF EXISTS articles_fts_insert;
+DROP TRIGGER IF EXISTS articles_fts_delete;
+DROP TRIGGER IF EXISTS articles_fts_update;
+DROP TABLE IF EXISTS articles_fts;`
+
+// searchWeights weights the bm25 ranking of the columns in the index, so that a match in the
+// title counts for more than one in the body.
+const searchWeights = `10.0, 10.0, 3.0, 1.0, 2.0`
+
+// Markers around the matching words in search snippets.
+const (
+	SNIPPET_START = "["
+	SNIPPET_END   = "]"
+)
+
+// SearchResult is an article matching a search, with an extract showing the match.
+type SearchResult struct {
+	Article
+	Snippet string
+}
+
+// createSearchIndex creates the full-text index and its triggers, indexing the articles that
+// are already stored if the index is new. An index keyed by rowid is replaced.
+func createSearchIndex(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'article_search_ids')").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		_, err = db.Exec(createSearchTriggersSQL)
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		dropRowidSearchSQL,
+		createSearchIDsSQL,
+		"INSERT INTO article_search_ids (guid) SELECT guid FROM articles ORDER BY rowid",
+		createSearchSQL,
+		"INSERT INTO articles_fts (articles_fts) VALUES ('rebuild')",
+		createSearchTriggersSQL,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SearchQuery converts free text into a full-text query matching articles containing every
+// word in it. Punctuation is ignored rather than read as query syntax, so that a search for
+// "Z8000?" works, but a word ending in * matches any word it is the start of.
+func SearchQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchArticles returns the articles best matching the free text, best first, with a
+// snippet of each showing where it matched.
+func (d *DB) SearchArticles(text string, limit int) ([]SearchResult, error) {
+	match := SearchQuery(text)
+	if match == "" {
+		return nil, nil
+	}
+
+	query := `SELECT ` + articleColumns + `, fts_snippet FROM articles
+              JOIN (SELECT s.guid AS fts_guid, bm25(articles_fts, ` + searchWeights + `) AS fts_rank,
+                    snippet(articles_fts, -1, ?, ?, '...', 16) AS fts_snippet
+                    FROM articles_fts JOIN article_search_ids s ON s.id = articles_fts.rowid
+                    WHERE articles_fts MATCH ?) ON articles.guid = fts_guid
+              ORDER BY fts_rank LIMIT ?`
+	rows, err := d.conn.Query(query, SNIPPET_START, SNIPPET_END, match, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRowsBOF(rows)
+
+	var results []SearchResult
+	for rows.Next() {
+		var result SearchResult
+		if err := scanArticle(rows, &result.Article, &result.Snippet); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	articles := make([]Article, len(results))
+	for i := range results {
+		articles[i] = results[i].Article
+	}
+	if err := d.loadTags(articles); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Tags = articles[i].Tags
+	}
+	return results, nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"single word", "z80", `"z80"`},
+		{"every word is required", "z80 computer", `"z80" "computer"`},
+		{"whitespace is collapsed", "  z80 \t computer\n", `"z80" "computer"`},
+		{"empty", "", ""},
+		{"only whitespace", "   ", ""},
+		{"punctuation is not syntax", "Z8000?", `"Z8000?"`},
+		{"operators are words", "z80 OR NOT 6502", `"z80" "OR" "NOT" "6502"`},
+		{"column filters are words", "title:z80", `"title:z80"`},
+		{"quotes are escaped", `say "hello"`, `"say" """hello"""`},
+		{"trailing star is a prefix", "emul*", `"emul"*`},
+		{"several stars are one prefix", "emul**", `"emul"*`},
+		{"star inside a word is kept", "c*pm", `"c*pm"`},
+		{"star alone is dropped", "z80 * computer", `"z80" "computer"`},
+		{"stars alone match nothing", "* **", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchQuery(tt.text); got != tt.want {
+				t.Errorf("SearchQuery(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchArticlesAfterVacuum(t *testing.T) {
+	db := newTestDB(t)
+	articles := []Article{
+		{GUID: "a", Title: "Library opening hours"},
+		{GUID: "b", Title: "Breadboard Z80 computer"},
+		{GUID: "c", Title: "Homebrew ham radio"},
+	}
+	for _, art := range articles {
+		if err := db.SaveArticle(art); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Deleting an article and vacuuming renumbers the rowids of the articles table, which
+	// the index must not depend on.
+	if _, err := db.conn.Exec("DELETE FROM articles WHERE guid = 'a'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec("VACUUM"); err != nil {
+		t.Fatal(err)
+	}
+
+	for text, want := range map[string]string{"z80": "b", "radio": "c"} {
+		results, err := db.SearchArticles(text, 10)
+		if err != nil {
+			t.Fatalf("SearchArticles(%q): %v", text, err)
+		}
+		if len(results) != 1 || results[0].GUID != want {
+			t.Errorf("SearchArticles(%q) = %v, want %s", text, results, want)
+		}
+	}
+}
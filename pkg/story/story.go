@@ -0,0 +1,132 @@
+package story
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// DEFAULT_SIMILARITY is the default minimum similarity for two articles to be grouped
+// into the same story.
+const DEFAULT_SIMILARITY = 0.35
+
+// MIN_WORD_LENGTH is the length below which words are ignored when comparing articles.
+const MIN_WORD_LENGTH = 3
+
+// stopwords are common words that say nothing about what an article is about.
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true, "from": true,
+	"are": true, "was": true, "its": true, "has": true, "have": true, "you": true, "your": true,
+	"but": true, "not": true, "all": true, "can": true, "one": true, "out": true, "into": true,
+	"new": true, "how": true, "what": true, "why": true, "now": true, "more": true, "about": true,
+	"just": true, "than": true, "they": true, "their": true, "will": true, "when": true, "which": true,
+}
+
+// words returns the set of significant lowercase words in the article's title and
+// description, preferring the translation if there is one.
+func words(art storage.Article) map[string]bool {
+	text := art.Title + " " + art.Description
+	if art.TranslatedTitle != "" {
+		text = art.TranslatedTitle + " " + art.TranslatedSummary
+	}
+
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len(w) >= MIN_WORD_LENGTH && !stopwords[w] {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// Similarity returns the Jaccard similarity of two sets of words, between 0 and 1.
+func Similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// score returns the article's score as an integer, treating a missing score as 0.
+func score(art storage.Article) int {
+	s, err := strconv.Atoi(art.Score)
+	if err != nil {
+		return 0
+	}
+	return s
+}
+
+// Group clusters articles that are about the same story. Any two articles whose similarity
+// is at least minSimilarity end up in the same story, as do articles linked through a chain
+// of similar ones. For each story the highest-scoring article becomes the headline and is
+// returned with the others in its Related field. Stories keep the order of their headlines
+// in the input.
+func Group(articles []storage.Article, minSimilarity float64) []storage.Article {
+	sets := make([]map[string]bool, len(articles))
+	for i, art := range articles {
+		sets[i] = words(art)
+	}
+
+	// Union-find over the article indexes.
+	parent := make([]int, len(articles))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range articles {
+		for j := i + 1; j < len(articles); j++ {
+			if Similarity(sets[i], sets[j]) >= minSimilarity {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range articles {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+
+	headlines := make([]int, 0, len(members))
+	for _, group := range members {
+		best := group[0]
+		for _, i := range group[1:] {
+			if score(articles[i]) > score(articles[best]) {
+				best = i
+			}
+		}
+		headlines = append(headlines, best)
+	}
+	sort.Ints(headlines)
+
+	stories := make([]storage.Article, 0, len(headlines))
+	for _, h := range headlines {
+		headline := articles[h]
+		headline.Related = nil
+		for _, i := range members[find(h)] {
+			if i != h {
+				headline.Related = append(headline.Related, articles[i])
+			}
+		}
+		stories = append(stories, headline)
+	}
+	return stories
+}
@@ -0,0 +1,179 @@
+package story
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/scottmbaker/ai-rss-scraper/pkg/storage"
+)
+
+// set returns a set of the words.
+func set(words ...string) map[string]bool {
+	s := make(map[string]bool)
+	for _, w := range words {
+		s[w] = true
+	}
+	return s
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b map[string]bool
+		want float64
+	}{
+		{"identical", set("z80", "computer"), set("z80", "computer"), 1},
+		{"disjoint", set("z80", "computer"), set("library", "council"), 0},
+		{"half shared", set("z80", "computer"), set("z80", "emulator"), 1.0 / 3},
+		{"subset", set("z80", "computer", "breadboard", "cpm"), set("z80", "computer"), 0.5},
+		{"first empty", set(), set("z80"), 0},
+		{"second empty", set("z80"), set(), 0},
+		{"both empty", set(), set(), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Similarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Similarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := Similarity(tt.b, tt.a); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Similarity(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		art  storage.Article
+		want map[string]bool
+	}{
+		{
+			"title and description, lowercased",
+			storage.Article{Title: "Z80 Emulator", Description: "Runs CP/M"},
+			set("z80", "emulator", "runs"),
+		},
+		{
+			"short words and stopwords are dropped",
+			storage.Article{Title: "How to build a new Z80 computer with the kids"},
+			set("build", "z80", "computer", "kids"),
+		},
+		{
+			"translation is preferred",
+			storage.Article{Title: "Ein Z80 Rechner", Description: "Gebaut", TranslatedTitle: "A Z80 computer", TranslatedSummary: "Built"},
+			set("z80", "computer", "built"),
+		},
+		{
+			"nothing significant",
+			storage.Article{Title: "It is on", Description: "and the"},
+			set(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := words(tt.art)
+			if len(got) != len(tt.want) {
+				t.Fatalf("words() = %v, want %v", got, tt.want)
+			}
+			for w := range tt.want {
+				if !got[w] {
+					t.Errorf("words() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// guids returns the GUIDs of the articles.
+func guids(articles []storage.Article) []string {
+	var ids []string
+	for _, art := range articles {
+		ids = append(ids, art.GUID)
+	}
+	return ids
+}
+
+func TestGroup(t *testing.T) {
+	articles := []storage.Article{
+		{GUID: "z80-a", Title: "Breadboard Z80 computer runs CP/M", Score: "70"},
+		{GUID: "library", Title: "Council extends library opening hours", Score: "20"},
+		{GUID: "z80-b", Title: "Z80 computer on a breadboard runs CP/M games", Score: "90"},
+		{GUID: "z80-c", Title: "Z80 breadboard games, now with sound", Score: "not scored"},
+		{GUID: "ham", Title: "Homebrew ham radio transceiver", Score: "50"},
+	}
+
+	tests := []struct {
+		name          string
+		minSimilarity float64
+		headlines     []string
+		related       map[string][]string
+	}{
+		{
+			"similar articles are grouped under the highest score",
+			DEFAULT_SIMILARITY,
+			[]string{"library", "z80-b", "ham"},
+			map[string][]string{"z80-b": {"z80-a", "z80-c"}},
+		},
+		{
+			"nothing is similar enough",
+			1.01,
+			[]string{"z80-a", "library", "z80-b", "z80-c", "ham"},
+			nil,
+		},
+		{
+			"everything is similar enough",
+			0,
+			[]string{"z80-b"},
+			map[string][]string{"z80-b": {"z80-a", "library", "z80-c", "ham"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stories := Group(articles, tt.minSimilarity)
+			if got := guids(stories); !slices.Equal(got, tt.headlines) {
+				t.Fatalf("headlines = %v, want %v", got, tt.headlines)
+			}
+			for _, s := range stories {
+				if got := guids(s.Related); !slices.Equal(got, tt.related[s.GUID]) {
+					t.Errorf("related to %s = %v, want %v", s.GUID, got, tt.related[s.GUID])
+				}
+			}
+		})
+	}
+}
+
+func TestGroupChains(t *testing.T) {
+	// a and c share nothing, but are linked through b.
+	articles := []storage.Article{
+		{GUID: "a", Title: "alpha bravo charlie", Score: "10"},
+		{GUID: "b", Title: "charlie delta echo", Score: "30"},
+		{GUID: "c", Title: "echo foxtrot golf", Score: "20"},
+	}
+	stories := Group(articles, 0.2)
+	if len(stories) != 1 {
+		t.Fatalf("got %d stories, want 1", len(stories))
+	}
+	if stories[0].GUID != "b" {
+		t.Errorf("headline = %s, want b", stories[0].GUID)
+	}
+	if got, want := guids(stories[0].Related), []string{"a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("related = %v, want %v", got, want)
+	}
+}
+
+func TestGroupEmpty(t *testing.T) {
+	if stories := Group(nil, DEFAULT_SIMILARITY); len(stories) != 0 {
+		t.Errorf("Group(nil) = %v, want none", stories)
+	}
+}
+
+func TestGroupClearsRelated(t *testing.T) {
+	articles := []storage.Article{
+		{GUID: "a", Title: "alpha bravo", Related: []storage.Article{{GUID: "stale"}}},
+	}
+	stories := Group(articles, DEFAULT_SIMILARITY)
+	if len(stories) != 1 || len(stories[0].Related) != 0 {
+		t.Errorf("Group() = %v, want a single story without related articles", stories)
+	}
+}